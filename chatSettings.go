@@ -0,0 +1,173 @@
+package main
+
+import (
+	"github.com/gameraccoon/telegram-prohibited-words-bot/matching"
+	"github.com/gameraccoon/telegram-prohibited-words-bot/processing"
+	"github.com/gameraccoon/telegram-prohibited-words-bot/schedule"
+	"github.com/nicksnyder/go-i18n/i18n"
+	"log"
+	"strings"
+	"time"
+)
+
+// names of per-chat settings stored in the database
+const (
+	stemmingSetting        = "stemming"
+	foldConfusablesSetting = "fold_confusables"
+	stripInvisibleSetting  = "strip_invisible"
+	collapseRepeatsSetting = "collapse_repeats"
+	mapLeetSetting         = "map_leet"
+	forwardPolicySetting   = "forward_policy"
+	transliterationSetting = "transliteration"
+	timezoneSetting        = "timezone"
+	languageSetting        = "language"
+)
+
+// what to do with prohibited words in forwarded messages
+const (
+	forwardPolicyIgnore = "ignore"
+	forwardPolicyFine   = "fine"
+	forwardPolicyReport = "report"
+)
+
+var forwardPolicies = []string{forwardPolicyIgnore, forwardPolicyFine, forwardPolicyReport}
+
+type entityKind struct {
+	// type of telegram message entity, also used in the /entities command
+	name string
+	// is the text of the entity skipped by default
+	defaultExcluded bool
+}
+
+var entityKinds = []entityKind{
+	{name: "url", defaultExcluded: true},
+	{name: "email", defaultExcluded: true},
+	{name: "text_link", defaultExcluded: false},
+	{name: "mention", defaultExcluded: true},
+	{name: "text_mention", defaultExcluded: false},
+	{name: "hashtag", defaultExcluded: false},
+	{name: "code", defaultExcluded: true},
+	{name: "pre", defaultExcluded: true},
+	{name: "spoiler", defaultExcluded: false},
+	{name: "blockquote", defaultExcluded: true},
+}
+
+type normalizationStep struct {
+	// name used in the /normalization command
+	name         string
+	setting      string
+	defaultValue bool
+}
+
+var normalizationSteps = []normalizationStep{
+	{name: "confusables", setting: foldConfusablesSetting, defaultValue: true},
+	{name: "invisible", setting: stripInvisibleSetting, defaultValue: true},
+	{name: "repeats", setting: collapseRepeatsSetting, defaultValue: false},
+	{name: "leet", setting: mapLeetSetting, defaultValue: false},
+}
+
+func parseSwitchValue(value string) (isEnabled bool, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "on", "1", "true", "вкл":
+		return true, true
+	case "off", "0", "false", "выкл":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+func getChatBoolSetting(staticData *processing.StaticProccessStructs, chatId int64, name string, defaultValue bool) bool {
+	defaultIntValue := int64(0)
+	if defaultValue {
+		defaultIntValue = 1
+	}
+	return staticData.Db.GetChatIntSetting(chatId, name, defaultIntValue) != 0
+}
+
+func setChatBoolSetting(staticData *processing.StaticProccessStructs, chatId int64, name string, value bool) {
+	intValue := int64(0)
+	if value {
+		intValue = 1
+	}
+	staticData.Db.SetChatIntSetting(chatId, name, intValue)
+}
+
+func isNormalizationStepEnabled(staticData *processing.StaticProccessStructs, chatId int64, setting string) bool {
+	for _, step := range normalizationSteps {
+		if step.setting == setting {
+			return getChatBoolSetting(staticData, chatId, step.setting, step.defaultValue)
+		}
+	}
+	return false
+}
+
+func getChatNormalizer(staticData *processing.StaticProccessStructs, chatId int64) matching.Normalizer {
+	return matching.Normalizer{
+		FoldConfusables: isNormalizationStepEnabled(staticData, chatId, foldConfusablesSetting),
+		StripInvisible:  isNormalizationStepEnabled(staticData, chatId, stripInvisibleSetting),
+		CollapseRepeats: isNormalizationStepEnabled(staticData, chatId, collapseRepeatsSetting),
+		MapLeet:         isNormalizationStepEnabled(staticData, chatId, mapLeetSetting),
+	}
+}
+
+func getForwardPolicy(staticData *processing.StaticProccessStructs, chatId int64) string {
+	return staticData.Db.GetChatStringSetting(chatId, forwardPolicySetting, forwardPolicyIgnore)
+}
+
+// schedules of words are checked in the time zone of the chat
+func getChatLocation(staticData *processing.StaticProccessStructs, chatId int64) *time.Location {
+	timezone := staticData.Db.GetChatStringSetting(chatId, timezoneSetting, "UTC")
+	location, err := schedule.LoadLocation(timezone)
+	if err != nil {
+		log.Printf("Can't load time zone '%s' of chat %d: %s", timezone, chatId, err.Error())
+		return time.UTC
+	}
+	return location
+}
+
+func getChatLanguage(staticData *processing.StaticProccessStructs, chatId int64) string {
+	return staticData.Db.GetChatStringSetting(chatId, languageSetting, staticData.Config.DefaultLanguage)
+}
+
+// missing translations fall back to the default language
+func getChatTrans(staticData *processing.StaticProccessStructs, chatId int64) i18n.TranslateFunc {
+	trans, err := i18n.Tfunc(getChatLanguage(staticData, chatId), staticData.Config.DefaultLanguage)
+	if err != nil {
+		log.Printf("Can't use language of chat %d: %s", chatId, err.Error())
+		return staticData.Trans
+	}
+	return trans
+}
+
+// finds a loaded language by its tag, "en" is enough for "en-us"
+func findLanguage(name string) (language string, ok bool) {
+	name = strings.ToLower(strings.Replace(name, "_", "-", -1))
+	for _, tag := range i18n.LanguageTags() {
+		if tag == name || strings.HasPrefix(tag, name+"-") {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+func getEntitySettingName(kind string) string {
+	return "exclude_entity_" + kind
+}
+
+// entity types which text is not scanned for prohibited words
+func getExcludedEntities(staticData *processing.StaticProccessStructs, chatId int64) map[string]bool {
+	excludedEntities := map[string]bool{}
+	for _, kind := range entityKinds {
+		if getChatBoolSetting(staticData, chatId, getEntitySettingName(kind.name), kind.defaultExcluded) {
+			excludedEntities[kind.name] = true
+		}
+	}
+
+	// collapsed quotes are the same quotes
+	if excludedEntities["blockquote"] {
+		excludedEntities["expandable_blockquote"] = true
+	}
+
+	return excludedEntities
+}
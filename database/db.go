@@ -7,6 +7,7 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	"log"
 	"strings"
+	"time"
 )
 
 func init() {
@@ -18,6 +19,122 @@ type Database struct {
 	conn *sql.DB
 }
 
+const (
+	// the word follows the chat stemming setting
+	WordStemmingDefault  = -1
+	WordStemmingDisabled = 0
+	WordStemmingEnabled  = 1
+)
+
+// flags of prohibited entries, whole-token case-insensitive matching if none are set
+const (
+	WordOptionCaseSensitive = 1
+	WordOptionSubstring     = 2
+	// only the token as it's written, without word forms, transliterations and typos
+	WordOptionWholeToken = 4
+)
+
+// what a prohibited entry is matched against
+const (
+	// words, phrases, patterns and emoji of message texts
+	WordKindText = 0
+	// name of a sticker set
+	WordKindStickerSet = 1
+	// unique id of a single sticker
+	WordKindSticker = 2
+	// domain of links, also matches its subdomains
+	WordKindDomain = 3
+)
+
+type ProhibitedWord struct {
+	Word        string
+	PatternType int
+	Stemming    int
+	// own weight of the word or the weight of its category
+	Weight int
+	// -1 if the word doesn't belong to any category
+	CategoryId int64
+	// maximal edit distance of fuzzy matches, 0 if the word is not matched fuzzily
+	FuzzyDistance int
+	Kind          int
+	// WordOption flags
+	Options int
+	// own schedule of the word or the schedule of its category, empty if the word is always active
+	Schedule string
+	// unix time when a temporary word stops being prohibited, 0 for permanent words
+	ExpiresAt int64
+}
+
+// an exemption with an empty word and without category covers all the words
+type Exemption struct {
+	UserId int64
+	Word   string
+	// -1 if the exemption is not for a category
+	CategoryId int64
+}
+
+// one fined use of a prohibited word
+type WordUsage struct {
+	UserId int64
+	Word   string
+	Weight int
+	// unix time, 0 for uses that were fined before the time was stored
+	UsedAt    int64
+	MessageId int64
+	// beginning of the message text, can be empty
+	Snippet   string
+	IsRevoked bool
+	// the use was taken away from the score by a reset
+	IsReset bool
+}
+
+type WordCount struct {
+	Word  string
+	Count int
+}
+
+// revoked uses are not counted in Count
+type UsageCounts struct {
+	Count        int
+	RevokedCount int
+}
+
+// zero values of the fields don't limit the result
+type UsageFilter struct {
+	// unix time range, From is included and To is not
+	From   int64
+	To     int64
+	UserId int64
+	Word   string
+	// the latest uses are returned first
+	Limit int
+}
+
+type ExpiredWord struct {
+	ChatId int64
+	Word   string
+}
+
+type FuzzyHit struct {
+	UserId   int64
+	Word     string
+	Surface  string
+	Distance int
+}
+
+type WordCategory struct {
+	Id        int64
+	Name      string
+	IsEnabled bool
+	Weight    int
+	Schedule  string
+}
+
+// temporary words are not prohibited anymore even before they are removed
+func getNotExpiredCondition() string {
+	return fmt.Sprintf("(expires_at IS NULL OR expires_at > %d)", time.Now().Unix())
+}
+
 func sanitizeString(input string) (result string) {
 	result = input
 	result = strings.Replace(result, "'", "''", -1)
@@ -55,6 +172,7 @@ func (database *Database) Connect(fileName string) error {
 		",chat_id INTEGER NOT NULL" +
 		",score INTEGER NOT NULL" +
 		",name STRING NOT NULL" +
+		",absent INTEGER" +
 		",PRIMARY KEY (messenger_id, chat_id)" +
 		")")
 
@@ -63,15 +181,101 @@ func (database *Database) Connect(fileName string) error {
 		",chat_id INTEGER NOT NULL" +
 		",word STRING NOT NULL" +
 		",removed INTEGER" +
+		",pattern_type INTEGER" +
+		",stemming INTEGER" +
+		",weight INTEGER" +
+		",category_id INTEGER" +
+		",fuzzy INTEGER" +
+		",kind INTEGER" +
+		",options INTEGER" +
+		",schedule STRING" +
+		",expires_at INTEGER" +
 		",UNIQUE(chat_id, word)" +
 		")")
 
+	database.execQuery("CREATE TABLE IF NOT EXISTS" +
+		" word_categories(id INTEGER NOT NULL PRIMARY KEY" +
+		",chat_id INTEGER NOT NULL" +
+		",name STRING NOT NULL" +
+		",disabled INTEGER" +
+		",weight INTEGER" +
+		",schedule STRING" +
+		",UNIQUE(chat_id, name)" +
+		")")
+
 	database.execQuery("CREATE TABLE IF NOT EXISTS" +
 		" used_words(id INTEGER NOT NULL PRIMARY KEY" +
 		",user_id INTEGER NOT NULL" +
 		",chat_id INTEGER NOT NULL" +
 		",word_id INTEGER NOT NULL" +
 		",revoked INTEGER" +
+		",weight INTEGER" +
+		",used_at INTEGER" +
+		",message_id INTEGER" +
+		",snippet STRING" +
+		",reset_id INTEGER" +
+		")")
+
+	database.execQuery("CREATE TABLE IF NOT EXISTS" +
+		" score_resets(id INTEGER NOT NULL PRIMARY KEY" +
+		",chat_id INTEGER NOT NULL" +
+		",created_at INTEGER NOT NULL" +
+		",undone INTEGER" +
+		")")
+
+	// points that a reset took away from every user, to give them back on undo
+	database.execQuery("CREATE TABLE IF NOT EXISTS" +
+		" score_reset_users(reset_id INTEGER NOT NULL" +
+		",user_id INTEGER NOT NULL" +
+		",score INTEGER NOT NULL" +
+		")")
+
+	database.execQuery("CREATE TABLE IF NOT EXISTS" +
+		" allowed_words(id INTEGER NOT NULL PRIMARY KEY" +
+		",chat_id INTEGER NOT NULL" +
+		",word STRING NOT NULL" +
+		",UNIQUE(chat_id, word)" +
+		")")
+
+	// recent fuzzy matches, to see what the fuzzy distance of words catches
+	database.execQuery("CREATE TABLE IF NOT EXISTS" +
+		" fuzzy_hits(id INTEGER NOT NULL PRIMARY KEY" +
+		",chat_id INTEGER NOT NULL" +
+		",user_id INTEGER NOT NULL" +
+		",word STRING NOT NULL" +
+		",surface STRING NOT NULL" +
+		",distance INTEGER NOT NULL" +
+		",created_at INTEGER" +
+		")")
+
+	// users that are not fined for some or all words, empty word and category -1 mean "not set"
+	database.execQuery("CREATE TABLE IF NOT EXISTS" +
+		" exemptions(id INTEGER NOT NULL PRIMARY KEY" +
+		",chat_id INTEGER NOT NULL" +
+		",user_id INTEGER NOT NULL" +
+		",word STRING NOT NULL" +
+		",category_id INTEGER NOT NULL" +
+		",UNIQUE(chat_id, user_id, word, category_id)" +
+		")")
+
+	// words that were already fined for a message, to not fine them again when it's edited
+	database.execQuery("CREATE TABLE IF NOT EXISTS" +
+		" message_words(id INTEGER NOT NULL PRIMARY KEY" +
+		",chat_id INTEGER NOT NULL" +
+		",message_id INTEGER NOT NULL" +
+		",word STRING NOT NULL" +
+		",created_at INTEGER" +
+		")")
+
+	database.execQuery("CREATE INDEX IF NOT EXISTS" +
+		" message_words_message_index ON message_words(chat_id, message_id)")
+
+	database.execQuery("CREATE TABLE IF NOT EXISTS" +
+		" chat_settings(chat_id INTEGER NOT NULL" +
+		",name TEXT NOT NULL" +
+		",integer_value INTEGER" +
+		",string_value STRING" +
+		",PRIMARY KEY (chat_id, name)" +
 		")")
 
 	return nil
@@ -158,15 +362,106 @@ func (database *Database) SetDatabaseVersion(version string) {
 	database.execQuery(fmt.Sprintf("INSERT INTO global_vars (name, string_value) VALUES ('version', '%s')", safeVersion))
 }
 
-func (database *Database) AddProhibitedWord(chatId int64,word string) {
+func (database *Database) SetChatIntSetting(chatId int64, name string, value int64) {
+	database.execQuery(fmt.Sprintf("INSERT OR REPLACE INTO chat_settings (chat_id, name, integer_value) VALUES (%d, '%s', %d)",
+		chatId,
+		sanitizeString(name),
+		value,
+	))
+}
+
+func (database *Database) GetChatIntSetting(chatId int64, name string, defaultValue int64) (value int64) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT integer_value FROM chat_settings WHERE chat_id=%d AND name='%s' AND integer_value IS NOT NULL",
+		chatId,
+		sanitizeString(name),
+	))
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err := rows.Scan(&value)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	} else {
+		value = defaultValue
+	}
+
+	return
+}
+
+func (database *Database) SetChatStringSetting(chatId int64, name string, value string) {
+	database.execQuery(fmt.Sprintf("INSERT OR REPLACE INTO chat_settings (chat_id, name, string_value) VALUES (%d, '%s', '%s')",
+		chatId,
+		sanitizeString(name),
+		sanitizeString(value),
+	))
+}
+
+func (database *Database) GetChatStringSetting(chatId int64, name string, defaultValue string) (value string) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT string_value FROM chat_settings WHERE chat_id=%d AND name='%s' AND string_value IS NOT NULL",
+		chatId,
+		sanitizeString(name),
+	))
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err := rows.Scan(&value)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	} else {
+		value = defaultValue
+	}
+
+	return
+}
+
+func (database *Database) AddProhibitedWord(chatId int64, word string, patternType int) {
 	// insert a new word if it doesn't exist
 	database.execQuery(fmt.Sprintf("INSERT OR IGNORE INTO prohibited_words (chat_id, word) VALUES (%d, '%s')",
 		chatId,
 		sanitizeString(word),
 	))
 
-	// mark word not removed if have been presented already
-	database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET removed=NULL WHERE chat_id=%d and word='%s'",
+	database.resetRemovedWordSettings(chatId, word)
+
+	// mark word not removed if have been presented already, a word added again is permanent
+	database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET removed=NULL, pattern_type=%d, kind=NULL, expires_at=NULL WHERE chat_id=%d and word='%s'",
+		patternType,
+		chatId,
+		sanitizeString(word),
+	))
+}
+
+// a removed word that is added again starts without its old category and settings
+func (database *Database) resetRemovedWordSettings(chatId int64, word string) {
+	database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET stemming=NULL, weight=NULL, category_id=NULL, fuzzy=NULL, options=NULL, schedule=NULL WHERE chat_id=%d and word='%s' AND removed IS NOT NULL",
+		chatId,
+		sanitizeString(word),
+	))
+}
+
+// a word that is already prohibited permanently stays permanent
+func (database *Database) AddTemporaryProhibitedWord(chatId int64, word string, patternType int, expiresAt int64) {
+	// a new word is inserted as removed to be restored the same way as removed ones
+	database.execQuery(fmt.Sprintf("INSERT OR IGNORE INTO prohibited_words (chat_id, word, removed) VALUES (%d, '%s', 1)",
+		chatId,
+		sanitizeString(word),
+	))
+
+	database.resetRemovedWordSettings(chatId, word)
+
+	database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET removed=NULL, pattern_type=%d, kind=NULL, expires_at=%d WHERE chat_id=%d and word='%s' AND (removed IS NOT NULL OR expires_at IS NOT NULL)",
+		patternType,
+		expiresAt,
 		chatId,
 		sanitizeString(word),
 	))
@@ -181,8 +476,9 @@ func (database *Database) RemoveProhibitedWord(chatId int64, word string) {
 }
 
 func (database *Database) GetProhibitedWords(chatId int64) (words []string) {
-	rows, err := database.conn.Query(fmt.Sprintf("SELECT word FROM prohibited_words WHERE chat_id=%d AND removed IS NULL ORDER BY word ASC",
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT word FROM prohibited_words WHERE chat_id=%d AND removed IS NULL AND %s ORDER BY word ASC",
 		chatId,
+		getNotExpiredCondition(),
 	))
 
 	if err != nil {
@@ -202,70 +498,151 @@ func (database *Database) GetProhibitedWords(chatId int64) (words []string) {
 	return
 }
 
-func (database *Database) GetUsersList(chatId int64) (ids []int64, names []string, scores []int) {
-	rows, err := database.conn.Query(fmt.Sprintf("SELECT messenger_id, name, score FROM users WHERE chat_id=%d ORDER BY score DESC",
+// expiresAt is unix time, 0 makes the word permanent
+func (database *Database) SetProhibitedWordExpiration(chatId int64, word string, expiresAt int64) {
+	expiresAtValue := "NULL"
+	if expiresAt > 0 {
+		expiresAtValue = fmt.Sprintf("%d", expiresAt)
+	}
+
+	database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET expires_at=%s WHERE chat_id=%d and word='%s'",
+		expiresAtValue,
 		chatId,
+		sanitizeString(word),
 	))
+}
+
+// marks temporary words which time is over as removed and returns them
+func (database *Database) RemoveExpiredWords() (words []ExpiredWord) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT id, chat_id, word FROM prohibited_words WHERE removed IS NULL AND NOT %s ORDER BY id ASC",
+		getNotExpiredCondition(),
+	))
+
 	if err != nil {
 		log.Fatal(err.Error())
 	}
-	defer rows.Close()
 
+	ids := []string{}
 	for rows.Next() {
 		var id int64
-		var name string
-		var score int
-		err := rows.Scan(&id, &name, &score)
+		var word ExpiredWord
+		err := rows.Scan(&id, &word.ChatId, &word.Word)
 		if err != nil {
 			log.Fatal(err.Error())
 		}
+		ids = append(ids, fmt.Sprintf("%d", id))
+		words = append(words, word)
+	}
 
-		ids = append(ids, id)
-		names = append(names, name)
-		scores = append(scores, score)
+	rows.Close()
+
+	if len(ids) > 0 {
+		database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET removed=1 WHERE id IN (%s)", strings.Join(ids, ",")))
 	}
 
 	return
 }
 
-func (database *Database) GetUserName(chatId int64, messengerUserId int64) (name string) {
-	rows, err := database.conn.Query(fmt.Sprintf("SELECT name FROM users WHERE chat_id=%d AND messenger_id=%d",
+func (database *Database) SetProhibitedWordStemming(chatId int64, word string, stemming int) {
+	stemmingValue := "NULL"
+	if stemming != WordStemmingDefault {
+		stemmingValue = fmt.Sprintf("%d", stemming)
+	}
+
+	database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET stemming=%s WHERE chat_id=%d and word='%s'",
+		stemmingValue,
 		chatId,
-		messengerUserId,
+		sanitizeString(word),
 	))
-	if err != nil {
-		log.Fatal(err.Error())
+}
+
+func (database *Database) SetProhibitedWordWeight(chatId int64, word string, weight int) {
+	database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET weight=%d WHERE chat_id=%d and word='%s'",
+		weight,
+		chatId,
+		sanitizeString(word),
+	))
+}
+
+// sets or clears one of WordOption flags of the word
+func (database *Database) SetProhibitedWordOption(chatId int64, word string, option int, isEnabled bool) {
+	optionsValue := fmt.Sprintf("IFNULL(options, 0) & ~%d", option)
+	if isEnabled {
+		optionsValue = fmt.Sprintf("IFNULL(options, 0) | %d", option)
 	}
-	defer rows.Close()
 
-	if rows.Next() {
-		err := rows.Scan(&name)
-		if err != nil {
-			log.Fatal(err.Error())
-		}
+	database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET options=%s WHERE chat_id=%d and word='%s'",
+		optionsValue,
+		chatId,
+		sanitizeString(word),
+	))
+}
+
+func (database *Database) SetProhibitedWordKind(chatId int64, word string, kind int) {
+	database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET kind=%d WHERE chat_id=%d and word='%s'",
+		kind,
+		chatId,
+		sanitizeString(word),
+	))
+}
+
+// distance 0 disables fuzzy matching of the word
+func (database *Database) SetProhibitedWordFuzzy(chatId int64, word string, distance int) {
+	distanceValue := "NULL"
+	if distance > 0 {
+		distanceValue = fmt.Sprintf("%d", distance)
 	}
 
-	return
+	database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET fuzzy=%s WHERE chat_id=%d and word='%s'",
+		distanceValue,
+		chatId,
+		sanitizeString(word),
+	))
 }
 
-func (database *Database) UpdateUser(chatId int64, messengerUserId int64, name string) {
-	sanitizedName := sanitizeString(name)
+// an empty schedule makes the word follow the schedule of its category
+func (database *Database) SetProhibitedWordSchedule(chatId int64, word string, schedule string) {
+	scheduleValue := "NULL"
+	if len(schedule) > 0 {
+		scheduleValue = fmt.Sprintf("'%s'", sanitizeString(schedule))
+	}
 
-	database.execQuery(fmt.Sprintf(
-		"INSERT OR IGNORE INTO users(messenger_id, chat_id, name, score) VALUES (%d, %d, '%s', 0);" +
-		"UPDATE users SET name='%s' WHERE messenger_id=%d",
-		messengerUserId,
+	database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET schedule=%s WHERE chat_id=%d and word='%s'",
+		scheduleValue,
 		chatId,
-		sanitizedName,
-		sanitizedName,
-		messengerUserId,
+		sanitizeString(word),
 	))
 }
 
-func (database *Database) GetUserScore(chatId int64, messengerUserId int64) (score int) {
-	rows, err := database.conn.Query(fmt.Sprintf("SELECT score FROM users WHERE messenger_id=%d AND chat_id=%d",
-		messengerUserId,
+// categoryId -1 removes the word from its category
+func (database *Database) SetProhibitedWordCategory(chatId int64, word string, categoryId int64) {
+	categoryValue := "NULL"
+	if categoryId != -1 {
+		categoryValue = fmt.Sprintf("%d", categoryId)
+	}
+
+	database.execQuery(fmt.Sprintf("UPDATE prohibited_words SET category_id=%s WHERE chat_id=%d and word='%s'",
+		categoryValue,
+		chatId,
+		sanitizeString(word),
+	))
+}
+
+// creates the category if it doesn't exist
+func (database *Database) GetOrCreateCategory(chatId int64, name string) int64 {
+	database.execQuery(fmt.Sprintf("INSERT OR IGNORE INTO word_categories (chat_id, name) VALUES (%d, '%s')",
+		chatId,
+		sanitizeString(name),
+	))
+
+	return database.GetCategoryId(chatId, name)
+}
+
+// returns -1 if there is no such category
+func (database *Database) GetCategoryId(chatId int64, name string) (categoryId int64) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT id FROM word_categories WHERE chat_id=%d AND name='%s'",
 		chatId,
+		sanitizeString(name),
 	))
 
 	if err != nil {
@@ -274,31 +651,52 @@ func (database *Database) GetUserScore(chatId int64, messengerUserId int64) (sco
 	defer rows.Close()
 
 	if rows.Next() {
-		err := rows.Scan(&score)
+		err := rows.Scan(&categoryId)
 		if err != nil {
 			log.Fatal(err.Error())
 		}
 	} else {
-		err = rows.Err()
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Fatal("No user found")
+		categoryId = -1
 	}
 
 	return
 }
 
-func (database *Database) getWordIds(groupId int64, words []string) (ids []int64) {
-	sanitizedWords := []string{}
-	for _, word := range words {
-		sanitizedWords = append(sanitizedWords, sanitizeString(word))
+func (database *Database) SetCategoryEnabled(categoryId int64, isEnabled bool) {
+	disabledValue := "1"
+	if isEnabled {
+		disabledValue = "NULL"
 	}
 
-	wordsSet := strings.Join(sanitizedWords, "','")
-	rows, err := database.conn.Query(fmt.Sprintf("SELECT id, word FROM prohibited_words WHERE chat_id=%d AND word IN ('%s')",
-		groupId,
-		wordsSet,
+	database.execQuery(fmt.Sprintf("UPDATE word_categories SET disabled=%s WHERE id=%d",
+		disabledValue,
+		categoryId,
+	))
+}
+
+func (database *Database) SetCategoryWeight(categoryId int64, weight int) {
+	database.execQuery(fmt.Sprintf("UPDATE word_categories SET weight=%d WHERE id=%d",
+		weight,
+		categoryId,
+	))
+}
+
+// an empty schedule makes the category always active
+func (database *Database) SetCategorySchedule(categoryId int64, schedule string) {
+	scheduleValue := "NULL"
+	if len(schedule) > 0 {
+		scheduleValue = fmt.Sprintf("'%s'", sanitizeString(schedule))
+	}
+
+	database.execQuery(fmt.Sprintf("UPDATE word_categories SET schedule=%s WHERE id=%d",
+		scheduleValue,
+		categoryId,
+	))
+}
+
+func (database *Database) GetCategories(chatId int64) (categories []WordCategory) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT id, name, IFNULL(disabled, 0), IFNULL(weight, 1), IFNULL(schedule, '') FROM word_categories WHERE chat_id=%d ORDER BY name ASC",
+		chatId,
 	))
 
 	if err != nil {
@@ -306,55 +704,684 @@ func (database *Database) getWordIds(groupId int64, words []string) (ids []int64
 	}
 	defer rows.Close()
 
-	wordsMap := map[string]int64{}
-
 	for rows.Next() {
-		var id int64
-		var word string
-		err := rows.Scan(&id, &word)
+		var category WordCategory
+		var isDisabled int
+		err := rows.Scan(&category.Id, &category.Name, &isDisabled, &category.Weight, &category.Schedule)
 		if err != nil {
 			log.Fatal(err.Error())
 		}
-		wordsMap[word] = id
-	}
-
-	for _, word := range words {
-		// can crash
-		ids = append(ids, wordsMap[word])
+		category.IsEnabled = (isDisabled == 0)
+		categories = append(categories, category)
 	}
 
 	return
 }
 
-func (database *Database) AddWordsUsage(chatId int64, messengerUserId int64, words []string) {
-	database.execQuery(fmt.Sprintf("UPDATE OR ROLLBACK users SET score=score+%d WHERE messenger_id=%d AND chat_id=%d",
-		len(words),
-		messengerUserId,
+func (database *Database) GetProhibitedWordsData(chatId int64) (words []ProhibitedWord) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT p.word, IFNULL(p.pattern_type, 0), IFNULL(p.stemming, -1), IFNULL(p.weight, IFNULL(c.weight, 1)), IFNULL(p.category_id, -1), IFNULL(p.fuzzy, 0), IFNULL(p.kind, 0), IFNULL(p.options, 0), IFNULL(p.schedule, IFNULL(c.schedule, '')), IFNULL(p.expires_at, 0) FROM prohibited_words as p LEFT JOIN word_categories as c ON p.category_id=c.id WHERE p.chat_id=%d AND p.removed IS NULL AND %s ORDER BY p.word ASC",
 		chatId,
+		getNotExpiredCondition(),
 	))
 
-	var buffer bytes.Buffer
-
-	buffer.WriteString("INSERT INTO used_words (chat_id, user_id, word_id) VALUES ")
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
 
-	wordIds := database.getWordIds(chatId, words)
+	for rows.Next() {
+		var word ProhibitedWord
+		err := rows.Scan(&word.Word, &word.PatternType, &word.Stemming, &word.Weight, &word.CategoryId, &word.FuzzyDistance, &word.Kind, &word.Options, &word.Schedule, &word.ExpiresAt)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		words = append(words, word)
+	}
+
+	return
+}
+
+func (database *Database) AddAllowedWord(chatId int64, word string) {
+	database.execQuery(fmt.Sprintf("INSERT OR IGNORE INTO allowed_words (chat_id, word) VALUES (%d, '%s')",
+		chatId,
+		sanitizeString(word),
+	))
+}
+
+func (database *Database) RemoveAllowedWord(chatId int64, word string) {
+	database.execQuery(fmt.Sprintf("DELETE FROM allowed_words WHERE chat_id=%d AND word='%s'",
+		chatId,
+		sanitizeString(word),
+	))
+}
+
+func (database *Database) GetAllowedWords(chatId int64) (words []string) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT word FROM allowed_words WHERE chat_id=%d ORDER BY word ASC",
+		chatId,
+	))
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var word string
+		err := rows.Scan(&word)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		words = append(words, word)
+	}
+
+	return
+}
+
+func getAbsentUsersCondition(includeAbsent bool, table string) string {
+	if includeAbsent {
+		return ""
+	}
+	return fmt.Sprintf(" AND %sabsent IS NULL", table)
+}
+
+// users that left the chat are listed only if includeAbsent is set,
+// users that were stored without being fined (e.g. to be exempted) are not listed
+func (database *Database) GetUsersList(chatId int64, includeAbsent bool) (ids []int64, names []string, scores []int) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT messenger_id, name, score FROM users WHERE chat_id=%d%s"+
+		" AND (score<>0 OR EXISTS (SELECT 1 FROM used_words as u WHERE u.chat_id=users.chat_id AND u.user_id=users.messenger_id))"+
+		" ORDER BY score DESC",
+		chatId,
+		getAbsentUsersCondition(includeAbsent, ""),
+	))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var name string
+		var score int
+		err := rows.Scan(&id, &name, &score)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		ids = append(ids, id)
+		names = append(names, name)
+		scores = append(scores, score)
+	}
+
+	return
+}
+
+// scores that users got for words from one category, revoked words are not counted
+func (database *Database) GetCategoryUsersList(chatId int64, categoryId int64, includeAbsent bool) (ids []int64, names []string, scores []int) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT s.messenger_id, s.name, SUM(IFNULL(u.weight, 1)) as category_score FROM used_words as u, prohibited_words as p, users as s"+
+		" WHERE u.chat_id=%d AND u.word_id=p.id AND p.category_id=%d AND u.revoked IS NULL AND u.reset_id IS NULL AND s.messenger_id=u.user_id AND s.chat_id=u.chat_id%s"+
+		" GROUP BY s.messenger_id ORDER BY category_score DESC",
+		chatId,
+		categoryId,
+		getAbsentUsersCondition(includeAbsent, "s."),
+	))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var name string
+		var score int
+		err := rows.Scan(&id, &name, &score)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		ids = append(ids, id)
+		names = append(names, name)
+		scores = append(scores, score)
+	}
+
+	return
+}
+
+func (database *Database) GetUserName(chatId int64, messengerUserId int64) (name string) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT name FROM users WHERE chat_id=%d AND messenger_id=%d",
+		chatId,
+		messengerUserId,
+	))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err := rows.Scan(&name)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	return
+}
+
+// returns -1 if there is no user with such name in the chat
+func (database *Database) GetUserIdByName(chatId int64, name string) (messengerUserId int64) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT messenger_id FROM users WHERE chat_id=%d AND LOWER(name)=LOWER('%s')",
+		chatId,
+		sanitizeString(name),
+	))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err := rows.Scan(&messengerUserId)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	} else {
+		messengerUserId = -1
+	}
+
+	return
+}
+
+// users that are not fined yet are not stored, so nothing is changed for them
+func (database *Database) SetUserAbsent(chatId int64, messengerUserId int64, isAbsent bool) {
+	absentValue := "NULL"
+	if isAbsent {
+		absentValue = "1"
+	}
+
+	database.execQuery(fmt.Sprintf("UPDATE users SET absent=%s WHERE messenger_id=%d AND chat_id=%d",
+		absentValue,
+		messengerUserId,
+		chatId,
+	))
+}
+
+func (database *Database) UpdateUser(chatId int64, messengerUserId int64, name string) {
+	sanitizedName := sanitizeString(name)
+
+	database.execQuery(fmt.Sprintf(
+		"INSERT OR IGNORE INTO users(messenger_id, chat_id, name, score) VALUES (%d, %d, '%s', 0);" +
+		"UPDATE users SET name='%s' WHERE messenger_id=%d",
+		messengerUserId,
+		chatId,
+		sanitizedName,
+		sanitizedName,
+		messengerUserId,
+	))
+}
+
+func (database *Database) GetUserScore(chatId int64, messengerUserId int64) (score int) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT score FROM users WHERE messenger_id=%d AND chat_id=%d",
+		messengerUserId,
+		chatId,
+	))
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err := rows.Scan(&score)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	} else {
+		err = rows.Err()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Fatal("No user found")
+	}
+
+	return
+}
+
+func (database *Database) getWordIdsAndWeights(groupId int64, words []string) (ids []int64, weights []int) {
+	sanitizedWords := []string{}
+	for _, word := range words {
+		sanitizedWords = append(sanitizedWords, sanitizeString(word))
+	}
+
+	wordsSet := strings.Join(sanitizedWords, "','")
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT p.id, p.word, IFNULL(p.weight, IFNULL(c.weight, 1)) FROM prohibited_words as p LEFT JOIN word_categories as c ON p.category_id=c.id WHERE p.chat_id=%d AND p.word IN ('%s')",
+		groupId,
+		wordsSet,
+	))
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	wordsMap := map[string]int64{}
+	weightsMap := map[string]int{}
+
+	for rows.Next() {
+		var id int64
+		var word string
+		var weight int
+		err := rows.Scan(&id, &word, &weight)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		wordsMap[word] = id
+		weightsMap[word] = weight
+	}
+
+	for _, word := range words {
+		// can crash
+		ids = append(ids, wordsMap[word])
+		weights = append(weights, weightsMap[word])
+	}
+
+	return
+}
+
+// returns weights of the used words in the same order
+// messageId 0 and an empty snippet are not stored
+func (database *Database) AddWordsUsage(chatId int64, messengerUserId int64, messageId int64, snippet string, words []string) (weights []int) {
+	wordIds, weights := database.getWordIdsAndWeights(chatId, words)
+
+	totalWeight := 0
+	for _, weight := range weights {
+		totalWeight += weight
+	}
+
+	database.execQuery(fmt.Sprintf("UPDATE OR ROLLBACK users SET score=score+%d WHERE messenger_id=%d AND chat_id=%d",
+		totalWeight,
+		messengerUserId,
+		chatId,
+	))
+
+	var buffer bytes.Buffer
+
+	messageIdValue := "NULL"
+	if messageId != 0 {
+		messageIdValue = fmt.Sprintf("%d", messageId)
+	}
+
+	snippetValue := "NULL"
+	if len(snippet) > 0 {
+		snippetValue = fmt.Sprintf("'%s'", sanitizeString(snippet))
+	}
+
+	usedAt := time.Now().Unix()
+
+	buffer.WriteString("INSERT INTO used_words (chat_id, user_id, word_id, weight, used_at, message_id, snippet) VALUES ")
 
 	isFirst := true
-	for _, wordId := range wordIds {
+	for idx, wordId := range wordIds {
 		if !isFirst {
 			buffer.WriteString(",")
 		}
 
-		buffer.WriteString(fmt.Sprintf("(%d,%d,%d)", chatId, messengerUserId, wordId))
+		buffer.WriteString(fmt.Sprintf("(%d,%d,%d,%d,%d,%s,%s)", chatId, messengerUserId, wordId, weights[idx], usedAt, messageIdValue, snippetValue))
 
 		isFirst = false
 	}
 
 	database.execQuery(buffer.String())
+
+	return
+}
+
+func (database *Database) GetWordsUsage(chatId int64, filter UsageFilter) (usages []WordUsage) {
+	var conditions bytes.Buffer
+
+	if filter.From != 0 {
+		conditions.WriteString(fmt.Sprintf(" AND u.used_at>=%d", filter.From))
+	}
+	if filter.To != 0 {
+		conditions.WriteString(fmt.Sprintf(" AND u.used_at<%d", filter.To))
+	}
+	if filter.UserId != 0 {
+		conditions.WriteString(fmt.Sprintf(" AND u.user_id=%d", filter.UserId))
+	}
+	if len(filter.Word) > 0 {
+		conditions.WriteString(fmt.Sprintf(" AND p.word='%s'", sanitizeString(filter.Word)))
+	}
+
+	limit := ""
+	if filter.Limit > 0 {
+		limit = fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT u.user_id, p.word, IFNULL(u.weight, 1), IFNULL(u.used_at, 0), IFNULL(u.message_id, 0), IFNULL(u.snippet, ''), IFNULL(u.revoked, 0), u.reset_id IS NOT NULL"+
+		" FROM used_words as u, prohibited_words as p WHERE u.chat_id=%d AND u.word_id=p.id%s ORDER BY u.id DESC%s",
+		chatId,
+		conditions.String(),
+		limit,
+	))
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var usage WordUsage
+		var isRevoked int
+		var isReset int
+		err := rows.Scan(&usage.UserId, &usage.Word, &usage.Weight, &usage.UsedAt, &usage.MessageId, &usage.Snippet, &isRevoked, &isReset)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		usage.IsRevoked = (isRevoked != 0)
+		usage.IsReset = (isReset != 0)
+		usages = append(usages, usage)
+	}
+
+	return
+}
+
+// from is unix time, 0 counts all the uses including those that were fined before the time was stored
+func (database *Database) GetUsageCounts(chatId int64, from int64) (counts UsageCounts) {
+	timeCondition := ""
+	if from != 0 {
+		timeCondition = fmt.Sprintf(" AND used_at>=%d", from)
+	}
+
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT IFNULL(SUM(revoked IS NULL), 0), IFNULL(SUM(revoked IS NOT NULL), 0) FROM used_words WHERE chat_id=%d%s",
+		chatId,
+		timeCondition,
+	))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err := rows.Scan(&counts.Count, &counts.RevokedCount)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	return
+}
+
+// the most used words without revoked uses, userId 0 counts uses of everyone
+func (database *Database) GetTopWords(chatId int64, messengerUserId int64, limit int) (words []WordCount) {
+	userCondition := ""
+	if messengerUserId != 0 {
+		userCondition = fmt.Sprintf(" AND u.user_id=%d", messengerUserId)
+	}
+
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT p.word, COUNT(*) as uses FROM used_words as u, prohibited_words as p"+
+		" WHERE u.chat_id=%d AND u.word_id=p.id AND u.revoked IS NULL%s GROUP BY p.id ORDER BY uses DESC, p.word ASC LIMIT %d",
+		chatId,
+		userCondition,
+		limit,
+	))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var word WordCount
+		err := rows.Scan(&word.Word, &word.Count)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		words = append(words, word)
+	}
+
+	return
+}
+
+func (database *Database) AddExemption(chatId int64, exemption Exemption) {
+	database.execQuery(fmt.Sprintf("INSERT OR IGNORE INTO exemptions (chat_id, user_id, word, category_id) VALUES (%d, %d, '%s', %d)",
+		chatId,
+		exemption.UserId,
+		sanitizeString(exemption.Word),
+		exemption.CategoryId,
+	))
+}
+
+func (database *Database) RemoveExemption(chatId int64, exemption Exemption) {
+	database.execQuery(fmt.Sprintf("DELETE FROM exemptions WHERE chat_id=%d AND user_id=%d AND word='%s' AND category_id=%d",
+		chatId,
+		exemption.UserId,
+		sanitizeString(exemption.Word),
+		exemption.CategoryId,
+	))
+}
+
+func (database *Database) RemoveUserExemptions(chatId int64, messengerUserId int64) {
+	database.execQuery(fmt.Sprintf("DELETE FROM exemptions WHERE chat_id=%d AND user_id=%d",
+		chatId,
+		messengerUserId,
+	))
+}
+
+func (database *Database) getExemptions(chatId int64, condition string) (exemptions []Exemption) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT user_id, word, category_id FROM exemptions WHERE chat_id=%d%s ORDER BY user_id ASC, id ASC",
+		chatId,
+		condition,
+	))
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var exemption Exemption
+		err := rows.Scan(&exemption.UserId, &exemption.Word, &exemption.CategoryId)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		exemptions = append(exemptions, exemption)
+	}
+
+	return
+}
+
+func (database *Database) GetExemptions(chatId int64) []Exemption {
+	return database.getExemptions(chatId, "")
+}
+
+func (database *Database) GetUserExemptions(chatId int64, messengerUserId int64) []Exemption {
+	return database.getExemptions(chatId, fmt.Sprintf(" AND user_id=%d", messengerUserId))
+}
+
+func (database *Database) AddMessageWords(chatId int64, messageId int64, words []string) {
+	if len(words) == 0 {
+		return
+	}
+
+	var buffer bytes.Buffer
+
+	buffer.WriteString("INSERT INTO message_words (chat_id, message_id, word, created_at) VALUES ")
+
+	createdAt := time.Now().Unix()
+	for idx, word := range words {
+		if idx > 0 {
+			buffer.WriteString(",")
+		}
+
+		buffer.WriteString(fmt.Sprintf("(%d,%d,'%s',%d)", chatId, messageId, sanitizeString(word), createdAt))
+	}
+
+	database.execQuery(buffer.String())
+}
+
+// words of messages that can't be edited anymore are not needed,
+// records made before the time was stored are removed too
+func (database *Database) RemoveMessageWordsBefore(createdBefore int64) {
+	database.execQuery(fmt.Sprintf("DELETE FROM message_words WHERE created_at IS NULL OR created_at<%d",
+		createdBefore,
+	))
+}
+
+// a word is returned as many times as it was fined
+func (database *Database) GetMessageWords(chatId int64, messageId int64) (words []string) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT word FROM message_words WHERE chat_id=%d AND message_id=%d",
+		chatId,
+		messageId,
+	))
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var word string
+		err := rows.Scan(&word)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		words = append(words, word)
+	}
+
+	return
+}
+
+func (database *Database) AddFuzzyHit(chatId int64, hit FuzzyHit) {
+	database.execQuery(fmt.Sprintf("INSERT INTO fuzzy_hits (chat_id, user_id, word, surface, distance, created_at) VALUES (%d, %d, '%s', '%s', %d, %d)",
+		chatId,
+		hit.UserId,
+		sanitizeString(hit.Word),
+		sanitizeString(hit.Surface),
+		hit.Distance,
+		time.Now().Unix(),
+	))
+}
+
+func (database *Database) RemoveFuzzyHitsBefore(createdBefore int64) {
+	database.execQuery(fmt.Sprintf("DELETE FROM fuzzy_hits WHERE created_at IS NULL OR created_at<%d",
+		createdBefore,
+	))
+}
+
+// the latest hits go first
+func (database *Database) GetLastFuzzyHits(chatId int64, count int) (hits []FuzzyHit) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT user_id, word, surface, distance FROM fuzzy_hits WHERE chat_id=%d ORDER BY id DESC LIMIT %d",
+		chatId,
+		count,
+	))
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hit FuzzyHit
+		err := rows.Scan(&hit.UserId, &hit.Word, &hit.Surface, &hit.Distance)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		hits = append(hits, hit)
+	}
+
+	return
+}
+
+// takes away points of uses that are neither revoked nor reset,
+// userId 0 and an empty word reset scores of everyone for all the words,
+// whole scores are set to zero unless only one word is reset
+func (database *Database) ResetScores(chatId int64, messengerUserId int64, word string) (resetId int64) {
+	resetId = database.createUniqueRecord("score_resets", fmt.Sprintf("NULL, %d, %d, NULL", chatId, time.Now().Unix()))
+
+	userCondition := ""
+	scoreUserCondition := ""
+	if messengerUserId != 0 {
+		userCondition = fmt.Sprintf(" AND user_id=%d", messengerUserId)
+		scoreUserCondition = fmt.Sprintf(" AND messenger_id=%d", messengerUserId)
+	}
+
+	wordCondition := ""
+	if len(word) > 0 {
+		wordCondition = fmt.Sprintf(" AND word_id IN (SELECT id FROM prohibited_words WHERE chat_id=%d AND word='%s')", chatId, sanitizeString(word))
+
+		database.execQuery(fmt.Sprintf("INSERT INTO score_reset_users (reset_id, user_id, score)"+
+			" SELECT %d, user_id, SUM(IFNULL(weight, 1)) FROM used_words WHERE chat_id=%d AND revoked IS NULL AND reset_id IS NULL%s%s GROUP BY user_id",
+			resetId,
+			chatId,
+			userCondition,
+			wordCondition,
+		))
+	} else {
+		database.execQuery(fmt.Sprintf("INSERT INTO score_reset_users (reset_id, user_id, score)"+
+			" SELECT %d, messenger_id, score FROM users WHERE chat_id=%d AND score<>0%s",
+			resetId,
+			chatId,
+			scoreUserCondition,
+		))
+	}
+
+	database.execQuery(fmt.Sprintf("UPDATE users SET score=score-(SELECT r.score FROM score_reset_users as r WHERE r.reset_id=%d AND r.user_id=users.messenger_id)"+
+		" WHERE chat_id=%d AND messenger_id IN (SELECT user_id FROM score_reset_users WHERE reset_id=%d)",
+		resetId,
+		chatId,
+		resetId,
+	))
+
+	database.execQuery(fmt.Sprintf("UPDATE used_words SET reset_id=%d WHERE chat_id=%d AND revoked IS NULL AND reset_id IS NULL%s%s",
+		resetId,
+		chatId,
+		userCondition,
+		wordCondition,
+	))
+
+	return
+}
+
+// returns -1 if there are no resets in the chat that can be undone
+func (database *Database) GetLastScoresReset(chatId int64) (resetId int64, createdAt int64) {
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT id, created_at FROM score_resets WHERE chat_id=%d AND undone IS NULL ORDER BY id DESC LIMIT 1",
+		chatId,
+	))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err := rows.Scan(&resetId, &createdAt)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	} else {
+		resetId = -1
+	}
+
+	return
+}
+
+// gives the points back, fines that were given after the reset are kept
+func (database *Database) UndoScoresReset(chatId int64, resetId int64) {
+	database.execQuery(fmt.Sprintf("UPDATE users SET score=score+(SELECT r.score FROM score_reset_users as r WHERE r.reset_id=%d AND r.user_id=users.messenger_id)"+
+		" WHERE chat_id=%d AND messenger_id IN (SELECT user_id FROM score_reset_users WHERE reset_id=%d)",
+		resetId,
+		chatId,
+		resetId,
+	))
+
+	database.execQuery(fmt.Sprintf("UPDATE used_words SET reset_id=NULL WHERE chat_id=%d AND reset_id=%d",
+		chatId,
+		resetId,
+	))
+
+	database.execQuery(fmt.Sprintf("UPDATE score_resets SET undone=1 WHERE chat_id=%d AND id=%d",
+		chatId,
+		resetId,
+	))
 }
 
 func (database *Database) RevokeLastUsedWords(chatId int64, wordsCount int, excludedUserId int64) (words []string, userId int64) {
-	rows, err := database.conn.Query(fmt.Sprintf("SELECT u.id, p.word, u.user_id, IFNULL(u.revoked, 0) FROM used_words as u, prohibited_words as p WHERE u.chat_id=%d AND u.word_id=p.id ORDER BY u.id DESC LIMIT %d",
+	rows, err := database.conn.Query(fmt.Sprintf("SELECT u.id, p.word, u.user_id, u.revoked IS NOT NULL OR u.reset_id IS NOT NULL, IFNULL(u.weight, 1) FROM used_words as u, prohibited_words as p WHERE u.chat_id=%d AND u.word_id=p.id ORDER BY u.id DESC LIMIT %d",
 		chatId,
 		wordsCount,
 	))
@@ -364,13 +1391,15 @@ func (database *Database) RevokeLastUsedWords(chatId int64, wordsCount int, excl
 
 	userId = int64(-1)
 	revokedIds := []int64{}
+	revokedWeight := 0
 
 	for rows.Next() {
 		var word string
 		var usedWordId int64
 		var isRevoked int
 		var lastUserId int64
-		err := rows.Scan(&usedWordId, &word, &lastUserId, &isRevoked)
+		var weight int
+		err := rows.Scan(&usedWordId, &word, &lastUserId, &isRevoked, &weight)
 		if err != nil {
 			log.Fatal(err.Error())
 		}
@@ -388,6 +1417,7 @@ func (database *Database) RevokeLastUsedWords(chatId int64, wordsCount int, excl
 		if isRevoked == 0 {
 			words = append(words, word)
 			revokedIds = append(revokedIds, usedWordId)
+			revokedWeight += weight
 		}
 	}
 
@@ -395,7 +1425,7 @@ func (database *Database) RevokeLastUsedWords(chatId int64, wordsCount int, excl
 
 	if len(revokedIds) > 0 {
 		database.execQuery(fmt.Sprintf("UPDATE OR ROLLBACK users SET score=score-%d WHERE messenger_id=%d AND chat_id=%d",
-			len(revokedIds),
+			revokedWeight,
 			userId,
 			chatId,
 		))
@@ -1,9 +1,11 @@
 package database
 
 import (
+	"database/sql"
 	"github.com/stretchr/testify/require"
 	"os"
 	"testing"
+	"time"
 )
 
 const (
@@ -114,7 +116,7 @@ func TestUpdateUser(t *testing.T) {
 	db.UpdateUser(chatId2, userId1, "test5")
 
 	{
-		ids, names, scores := db.GetUsersList(chatId1)
+		ids, names, scores := db.GetUsersList(chatId1, false)
 
 		for idx, id := range ids {
 			if id == userId1 {
@@ -131,7 +133,7 @@ func TestUpdateUser(t *testing.T) {
 	}
 
 	{
-		ids, names, scores := db.GetUsersList(chatId2)
+		ids, names, scores := db.GetUsersList(chatId2, false)
 
 		for idx, id := range ids {
 			if id == userId1 {
@@ -181,9 +183,9 @@ func TestAddAndRemoveProhibitedWord(t *testing.T) {
 	prohibitedWord2 := "testWord2"
 
 	{
-		db.AddProhibitedWord(chatId1, prohibitedWord1)
+		db.AddProhibitedWord(chatId1, prohibitedWord1, 0)
 		assert.Equal(1, len(db.GetProhibitedWords(chatId1)))
-		db.AddProhibitedWord(chatId1, prohibitedWord1)
+		db.AddProhibitedWord(chatId1, prohibitedWord1, 0)
 		assert.Equal(1, len(db.GetProhibitedWords(chatId1)))
 		assert.Equal(prohibitedWord1, db.GetProhibitedWords(chatId1)[0])
 		db.RemoveProhibitedWord(chatId1, prohibitedWord1)
@@ -191,10 +193,10 @@ func TestAddAndRemoveProhibitedWord(t *testing.T) {
 	}
 
 	{
-		db.AddProhibitedWord(chatId2, prohibitedWord2)
+		db.AddProhibitedWord(chatId2, prohibitedWord2, 0)
 		assert.Equal(1, len(db.GetProhibitedWords(chatId2)))
 		assert.Equal(prohibitedWord2, db.GetProhibitedWords(chatId2)[0])
-		db.AddProhibitedWord(chatId2, prohibitedWord1)
+		db.AddProhibitedWord(chatId2, prohibitedWord1, 0)
 		assert.Equal(2, len(db.GetProhibitedWords(chatId2)))
 		db.RemoveProhibitedWord(chatId2, prohibitedWord2)
 		assert.Equal(1, len(db.GetProhibitedWords(chatId2)))
@@ -219,18 +221,18 @@ func TestScoringUsers(t *testing.T) {
 
 	db.UpdateUser(chatId, userId1, "testName1")
 	db.UpdateUser(chatId, userId2, "testName2")
-	db.AddProhibitedWord(chatId, prohibitedWord)
+	db.AddProhibitedWord(chatId, prohibitedWord, 0)
 
 	assert.Equal(0, db.GetUserScore(chatId, userId1))
 	assert.Equal(0, db.GetUserScore(chatId, userId2))
 
-	db.AddWordsUsage(chatId, userId1, []string{prohibitedWord})
-	db.AddWordsUsage(chatId, userId2, []string{prohibitedWord, prohibitedWord})
+	db.AddWordsUsage(chatId, userId1, 0, "", []string{prohibitedWord})
+	db.AddWordsUsage(chatId, userId2, 0, "", []string{prohibitedWord, prohibitedWord})
 
 	assert.Equal(1, db.GetUserScore(chatId, userId1))
 	assert.Equal(2, db.GetUserScore(chatId, userId2))
 
-	ids, names, score := db.GetUsersList(chatId)
+	ids, names, score := db.GetUsersList(chatId, false)
 
 	assert.Equal(2, len(ids))
 	if len(ids) > 1 {
@@ -263,11 +265,11 @@ func TestRevokingScores(t *testing.T) {
 
 	db.UpdateUser(chatId, userId1, userName1)
 	db.UpdateUser(chatId, userId2, userName2)
-	db.AddProhibitedWord(chatId, prohibitedWord1)
-	db.AddProhibitedWord(chatId, prohibitedWord2)
+	db.AddProhibitedWord(chatId, prohibitedWord1, 0)
+	db.AddProhibitedWord(chatId, prohibitedWord2, 0)
 
-	db.AddWordsUsage(chatId, userId1, []string{prohibitedWord1})
-	db.AddWordsUsage(chatId, userId2, []string{prohibitedWord1, prohibitedWord2})
+	db.AddWordsUsage(chatId, userId1, 0, "", []string{prohibitedWord1})
+	db.AddWordsUsage(chatId, userId2, 0, "", []string{prohibitedWord1, prohibitedWord2})
 
 	{
 		words, userId := db.RevokeLastUsedWords(chatId, 4, userId2)
@@ -317,3 +319,952 @@ func TestRevokingScores(t *testing.T) {
 	assert.Equal(1, db.GetUserScore(chatId, userId1))
 	assert.Equal(0, db.GetUserScore(chatId, userId2))
 }
+
+func TestProhibitedWordPatternType(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+
+	db.AddProhibitedWord(chatId, "word", 0)
+	db.AddProhibitedWord(chatId, "f+u+c+k", 2)
+
+	{
+		words := db.GetProhibitedWordsData(chatId)
+		assert.Equal(2, len(words))
+		assert.Equal("f+u+c+k", words[0].Word)
+		assert.Equal(2, words[0].PatternType)
+		assert.Equal("word", words[1].Word)
+		assert.Equal(0, words[1].PatternType)
+	}
+
+	// re-adding changes the type of the existing entry
+	db.AddProhibitedWord(chatId, "word", 1)
+
+	{
+		words := db.GetProhibitedWordsData(chatId)
+		assert.Equal(2, len(words))
+		assert.Equal(1, words[1].PatternType)
+	}
+}
+
+func TestReaddedWordSettings(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+
+	categoryId := db.GetOrCreateCategory(chatId, "category")
+	for _, word := range []string{"active", "removed"} {
+		db.AddProhibitedWord(chatId, word, 0)
+		db.SetProhibitedWordCategory(chatId, word, categoryId)
+		db.SetProhibitedWordStemming(chatId, word, WordStemmingDisabled)
+		db.SetProhibitedWordWeight(chatId, word, 5)
+		db.SetProhibitedWordFuzzy(chatId, word, 2)
+		db.SetProhibitedWordSchedule(chatId, word, "sat")
+	}
+	db.RemoveProhibitedWord(chatId, "removed")
+
+	// adding an active word again keeps its settings
+	db.AddProhibitedWord(chatId, "active", 0)
+	// a removed word is added as a new one
+	db.AddProhibitedWord(chatId, "removed", 0)
+
+	words := db.GetProhibitedWordsData(chatId)
+	assert.Equal(2, len(words))
+	assert.Equal("active", words[0].Word)
+	assert.Equal(categoryId, words[0].CategoryId)
+	assert.Equal(WordStemmingDisabled, words[0].Stemming)
+	assert.Equal(5, words[0].Weight)
+	assert.Equal(2, words[0].FuzzyDistance)
+	assert.Equal("sat", words[0].Schedule)
+	assert.Equal("removed", words[1].Word)
+	assert.Equal(int64(-1), words[1].CategoryId)
+	assert.Equal(WordStemmingDefault, words[1].Stemming)
+	assert.Equal(1, words[1].Weight)
+	assert.Equal(0, words[1].FuzzyDistance)
+	assert.Equal("", words[1].Schedule)
+
+	// the same for temporary words
+	db.RemoveProhibitedWord(chatId, "active")
+	db.AddTemporaryProhibitedWord(chatId, "active", 0, time.Now().Unix()+60)
+	words = db.GetProhibitedWordsData(chatId)
+	assert.Equal(int64(-1), words[0].CategoryId)
+	assert.Equal(1, words[0].Weight)
+}
+
+func TestMakeUpdaters(t *testing.T) {
+	assert := require.New(t)
+
+	allUpdaters := makeAllUpdaters()
+
+	assert.Equal(len(allUpdaters), len(makeUpdaters(minimalVersion, latestVersion)))
+	assert.Equal(len(allUpdaters)-1, len(makeUpdaters("1.1", latestVersion)))
+	assert.Equal(0, len(makeUpdaters(latestVersion, latestVersion)))
+}
+
+func TestChatSettings(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId1 int64 = 321
+	var chatId2 int64 = 123
+
+	assert.Equal(int64(5), db.GetChatIntSetting(chatId1, "test", 5))
+	assert.Equal("default", db.GetChatStringSetting(chatId1, "test2", "default"))
+
+	db.SetChatIntSetting(chatId1, "test", 1)
+	db.SetChatStringSetting(chatId1, "test2", "it's value")
+
+	assert.Equal(int64(1), db.GetChatIntSetting(chatId1, "test", 5))
+	assert.Equal("it's value", db.GetChatStringSetting(chatId1, "test2", "default"))
+	assert.Equal(int64(5), db.GetChatIntSetting(chatId2, "test", 5))
+
+	db.SetChatIntSetting(chatId1, "test", 0)
+	assert.Equal(int64(0), db.GetChatIntSetting(chatId1, "test", 5))
+}
+
+func TestProhibitedWordStemming(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+
+	db.AddProhibitedWord(chatId, "word", 0)
+	assert.Equal(WordStemmingDefault, db.GetProhibitedWordsData(chatId)[0].Stemming)
+
+	db.SetProhibitedWordStemming(chatId, "word", WordStemmingDisabled)
+	assert.Equal(WordStemmingDisabled, db.GetProhibitedWordsData(chatId)[0].Stemming)
+
+	db.SetProhibitedWordStemming(chatId, "word", WordStemmingDefault)
+	assert.Equal(WordStemmingDefault, db.GetProhibitedWordsData(chatId)[0].Stemming)
+}
+
+func TestAllowedWords(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId1 int64 = 321
+	var chatId2 int64 = 123
+
+	db.AddAllowedWord(chatId1, "хлеб")
+	db.AddAllowedWord(chatId1, "хлеб")
+	db.AddAllowedWord(chatId1, "as'd")
+	db.AddAllowedWord(chatId2, "хлебушек")
+
+	assert.Equal([]string{"as'd", "хлеб"}, db.GetAllowedWords(chatId1))
+	assert.Equal([]string{"хлебушек"}, db.GetAllowedWords(chatId2))
+
+	db.RemoveAllowedWord(chatId1, "хлеб")
+	assert.Equal([]string{"as'd"}, db.GetAllowedWords(chatId1))
+}
+
+func TestWeightedScoring(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+
+	var userId1 int64 = 1234
+	var userId2 int64 = 4321
+	mildWord := "mild"
+	strongWord := "strong"
+
+	db.UpdateUser(chatId, userId1, "testName1")
+	db.UpdateUser(chatId, userId2, "testName2")
+	db.AddProhibitedWord(chatId, mildWord, 0)
+	db.AddProhibitedWord(chatId, strongWord, 0)
+	db.SetProhibitedWordWeight(chatId, strongWord, 5)
+
+	{
+		words := db.GetProhibitedWordsData(chatId)
+		assert.Equal(1, words[0].Weight)
+		assert.Equal(5, words[1].Weight)
+	}
+
+	assert.Equal([]int{1, 5, 5}, db.AddWordsUsage(chatId, userId1, 0, "", []string{mildWord, strongWord, strongWord}))
+	assert.Equal(11, db.GetUserScore(chatId, userId1))
+
+	// changing weight doesn't affect already used words
+	db.SetProhibitedWordWeight(chatId, strongWord, 2)
+
+	{
+		words, userId := db.RevokeLastUsedWords(chatId, 2, userId2)
+		assert.Equal(userId1, userId)
+		assert.Equal(2, len(words))
+	}
+
+	assert.Equal(1, db.GetUserScore(chatId, userId1))
+
+	assert.Equal([]int{2}, db.AddWordsUsage(chatId, userId2, 0, "", []string{strongWord}))
+	assert.Equal(2, db.GetUserScore(chatId, userId2))
+}
+
+func TestWordCategories(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+	var otherChatId int64 = 321
+
+	var userId1 int64 = 1234
+	var userId2 int64 = 4321
+
+	assert.Equal(int64(-1), db.GetCategoryId(chatId, "politics"))
+
+	politicsId := db.GetOrCreateCategory(chatId, "politics")
+	assert.NotEqual(int64(-1), politicsId)
+	assert.Equal(politicsId, db.GetOrCreateCategory(chatId, "politics"))
+	assert.Equal(politicsId, db.GetCategoryId(chatId, "politics"))
+	assert.Equal(int64(-1), db.GetCategoryId(otherChatId, "politics"))
+
+	workId := db.GetOrCreateCategory(chatId, "work")
+
+	db.AddProhibitedWord(chatId, "elections", 0)
+	db.SetProhibitedWordCategory(chatId, "elections", politicsId)
+	db.AddProhibitedWord(chatId, "party", 0)
+	db.SetProhibitedWordCategory(chatId, "party", politicsId)
+	db.SetProhibitedWordWeight(chatId, "party", 2)
+	db.AddProhibitedWord(chatId, "job", 0)
+	db.SetProhibitedWordCategory(chatId, "job", workId)
+	db.AddProhibitedWord(chatId, "word", 0)
+
+	db.SetCategoryWeight(politicsId, 3)
+	db.SetCategoryEnabled(workId, false)
+
+	{
+		categories := db.GetCategories(chatId)
+		assert.Equal(2, len(categories))
+		assert.Equal("politics", categories[0].Name)
+		assert.True(categories[0].IsEnabled)
+		assert.Equal(3, categories[0].Weight)
+		assert.Equal("work", categories[1].Name)
+		assert.False(categories[1].IsEnabled)
+		assert.Equal(1, categories[1].Weight)
+	}
+
+	{
+		words := db.GetProhibitedWordsData(chatId)
+		assert.Equal(4, len(words))
+		assert.Equal("elections", words[0].Word)
+		assert.Equal(politicsId, words[0].CategoryId)
+		// category weight is used when the word doesn't have its own
+		assert.Equal(3, words[0].Weight)
+		assert.Equal("job", words[1].Word)
+		assert.Equal(workId, words[1].CategoryId)
+		assert.Equal("party", words[2].Word)
+		assert.Equal(2, words[2].Weight)
+		assert.Equal("word", words[3].Word)
+		assert.Equal(int64(-1), words[3].CategoryId)
+	}
+
+	db.UpdateUser(chatId, userId1, "testName1")
+	db.UpdateUser(chatId, userId2, "testName2")
+
+	assert.Equal([]int{3, 2, 1}, db.AddWordsUsage(chatId, userId1, 0, "", []string{"elections", "party", "word"}))
+	assert.Equal([]int{3, 3}, db.AddWordsUsage(chatId, userId2, 0, "", []string{"elections", "elections"}))
+	db.AddWordsUsage(chatId, userId2, 0, "", []string{"job"})
+
+	{
+		ids, names, scores := db.GetCategoryUsersList(chatId, politicsId, false)
+		assert.Equal([]int64{userId2, userId1}, ids)
+		assert.Equal([]string{"testName2", "testName1"}, names)
+		assert.Equal([]int{6, 5}, scores)
+	}
+
+	db.SetProhibitedWordCategory(chatId, "elections", -1)
+	assert.Equal(int64(-1), db.GetProhibitedWordsData(chatId)[0].CategoryId)
+}
+
+func TestMessageWords(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+	var otherChatId int64 = 321
+
+	assert.Equal(0, len(db.GetMessageWords(chatId, 10)))
+
+	db.AddMessageWords(chatId, 10, []string{"word1", "word2", "word1"})
+	db.AddMessageWords(chatId, 10, []string{"it's"})
+	db.AddMessageWords(chatId, 11, []string{"word3"})
+	db.AddMessageWords(otherChatId, 10, []string{"word4"})
+	db.AddMessageWords(chatId, 12, []string{})
+
+	assert.Equal([]string{"word1", "word2", "word1", "it's"}, db.GetMessageWords(chatId, 10))
+	assert.Equal([]string{"word3"}, db.GetMessageWords(chatId, 11))
+	assert.Equal([]string{"word4"}, db.GetMessageWords(otherChatId, 10))
+	assert.Equal(0, len(db.GetMessageWords(chatId, 12)))
+}
+
+func TestFuzzyWords(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+	var otherChatId int64 = 321
+
+	db.AddProhibitedWord(chatId, "word1", 0)
+	db.AddProhibitedWord(chatId, "word2", 0)
+
+	db.SetProhibitedWordFuzzy(chatId, "word1", 2)
+	{
+		words := db.GetProhibitedWordsData(chatId)
+		assert.Equal(2, words[0].FuzzyDistance)
+		assert.Equal(0, words[1].FuzzyDistance)
+	}
+
+	db.SetProhibitedWordFuzzy(chatId, "word1", 0)
+	assert.Equal(0, db.GetProhibitedWordsData(chatId)[0].FuzzyDistance)
+
+	assert.Equal(0, len(db.GetLastFuzzyHits(chatId, 10)))
+
+	db.AddFuzzyHit(chatId, FuzzyHit{UserId: 1, Word: "word1", Surface: "wrod1", Distance: 1})
+	db.AddFuzzyHit(chatId, FuzzyHit{UserId: 2, Word: "word2", Surface: "wor'd", Distance: 2})
+	db.AddFuzzyHit(otherChatId, FuzzyHit{UserId: 1, Word: "word1", Surface: "wodr1", Distance: 1})
+
+	assert.Equal([]FuzzyHit{
+		{UserId: 2, Word: "word2", Surface: "wor'd", Distance: 2},
+		{UserId: 1, Word: "word1", Surface: "wrod1", Distance: 1},
+	}, db.GetLastFuzzyHits(chatId, 10))
+
+	assert.Equal(1, len(db.GetLastFuzzyHits(chatId, 1)))
+}
+
+func TestProhibitedWordKind(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+
+	db.AddProhibitedWord(chatId, "word", 0)
+	db.AddProhibitedWord(chatId, "stickers_by_bot", 0)
+	db.SetProhibitedWordKind(chatId, "stickers_by_bot", WordKindStickerSet)
+	db.AddProhibitedWord(chatId, "AgADBAADx", 0)
+	db.SetProhibitedWordKind(chatId, "AgADBAADx", WordKindSticker)
+
+	{
+		words := db.GetProhibitedWordsData(chatId)
+		assert.Equal(3, len(words))
+		assert.Equal("AgADBAADx", words[0].Word)
+		assert.Equal(WordKindSticker, words[0].Kind)
+		assert.Equal("stickers_by_bot", words[1].Word)
+		assert.Equal(WordKindStickerSet, words[1].Kind)
+		assert.Equal("word", words[2].Word)
+		assert.Equal(WordKindText, words[2].Kind)
+	}
+
+	// adding the same entry as a word makes it a word again
+	db.AddProhibitedWord(chatId, "stickers_by_bot", 0)
+	assert.Equal(WordKindText, db.GetProhibitedWordsData(chatId)[1].Kind)
+}
+
+func TestProhibitedWordOptions(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+
+	db.AddProhibitedWord(chatId, "IT", 0)
+	db.AddProhibitedWord(chatId, "it", 0)
+	assert.Equal(2, len(db.GetProhibitedWordsData(chatId)))
+	assert.Equal(0, db.GetProhibitedWordsData(chatId)[0].Options)
+
+	db.SetProhibitedWordOption(chatId, "IT", WordOptionCaseSensitive, true)
+	db.SetProhibitedWordOption(chatId, "IT", WordOptionSubstring, true)
+	assert.Equal(WordOptionCaseSensitive|WordOptionSubstring, db.GetProhibitedWordsData(chatId)[0].Options)
+	assert.Equal(0, db.GetProhibitedWordsData(chatId)[1].Options)
+
+	db.SetProhibitedWordOption(chatId, "IT", WordOptionSubstring, false)
+	assert.Equal(WordOptionCaseSensitive, db.GetProhibitedWordsData(chatId)[0].Options)
+
+	db.SetProhibitedWordOption(chatId, "IT", WordOptionCaseSensitive, false)
+	assert.Equal(0, db.GetProhibitedWordsData(chatId)[0].Options)
+}
+
+func TestProhibitedWordSchedules(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+
+	db.AddProhibitedWord(chatId, "politics", 0)
+	db.AddProhibitedWord(chatId, "work", 0)
+	categoryId := db.GetOrCreateCategory(chatId, "evening")
+	db.SetProhibitedWordCategory(chatId, "politics", categoryId)
+	assert.Equal("", db.GetProhibitedWordsData(chatId)[0].Schedule)
+
+	// words of a category follow its schedule
+	db.SetCategorySchedule(categoryId, "22:00-06:00")
+	assert.Equal("22:00-06:00", db.GetCategories(chatId)[0].Schedule)
+	assert.Equal("22:00-06:00", db.GetProhibitedWordsData(chatId)[0].Schedule)
+	assert.Equal("", db.GetProhibitedWordsData(chatId)[1].Schedule)
+
+	// own schedule of a word goes first
+	db.SetProhibitedWordSchedule(chatId, "politics", "sat-sun")
+	db.SetProhibitedWordSchedule(chatId, "work", "sat-sun")
+	assert.Equal("sat-sun", db.GetProhibitedWordsData(chatId)[0].Schedule)
+	assert.Equal("sat-sun", db.GetProhibitedWordsData(chatId)[1].Schedule)
+
+	db.SetProhibitedWordSchedule(chatId, "politics", "")
+	db.SetProhibitedWordSchedule(chatId, "work", "")
+	assert.Equal("22:00-06:00", db.GetProhibitedWordsData(chatId)[0].Schedule)
+	assert.Equal("", db.GetProhibitedWordsData(chatId)[1].Schedule)
+
+	db.SetCategorySchedule(categoryId, "")
+	assert.Equal("", db.GetCategories(chatId)[0].Schedule)
+	assert.Equal("", db.GetProhibitedWordsData(chatId)[0].Schedule)
+}
+
+func TestTemporaryWords(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+	now := time.Now().Unix()
+
+	db.AddProhibitedWord(chatId, "joke", 0)
+	db.AddProhibitedWord(chatId, "old joke", 0)
+	db.AddProhibitedWord(chatId, "word", 0)
+	db.SetProhibitedWordExpiration(chatId, "joke", now+60*60)
+	db.SetProhibitedWordExpiration(chatId, "old joke", now-1)
+
+	// expired words are not prohibited even before they are removed
+	assert.Equal([]string{"joke", "word"}, db.GetProhibitedWords(chatId))
+	words := db.GetProhibitedWordsData(chatId)
+	assert.Equal(2, len(words))
+	assert.Equal(now+60*60, words[0].ExpiresAt)
+	assert.Equal(int64(0), words[1].ExpiresAt)
+
+	assert.Equal([]ExpiredWord{{ChatId: chatId, Word: "old joke"}}, db.RemoveExpiredWords())
+	assert.Equal(0, len(db.RemoveExpiredWords()))
+
+	// a word that is added again is permanent
+	db.AddProhibitedWord(chatId, "old joke", 0)
+	assert.Equal([]string{"joke", "old joke", "word"}, db.GetProhibitedWords(chatId))
+	assert.Equal(0, len(db.RemoveExpiredWords()))
+
+	db.SetProhibitedWordExpiration(chatId, "joke", 0)
+	assert.Equal(int64(0), db.GetProhibitedWordsData(chatId)[0].ExpiresAt)
+}
+
+func TestTemporaryWordsDontReplacePermanentOnes(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+	now := time.Now().Unix()
+
+	db.AddProhibitedWord(chatId, "permanent", 0)
+	db.AddProhibitedWord(chatId, "removed", 0)
+	db.RemoveProhibitedWord(chatId, "removed")
+	db.AddTemporaryProhibitedWord(chatId, "temporary", 0, now+60)
+
+	db.AddTemporaryProhibitedWord(chatId, "new", 0, now+60*60)
+	db.AddTemporaryProhibitedWord(chatId, "permanent", 0, now-1)
+	db.AddTemporaryProhibitedWord(chatId, "removed", 0, now+60*60)
+	db.AddTemporaryProhibitedWord(chatId, "temporary", 0, now+60*60)
+
+	words := db.GetProhibitedWordsData(chatId)
+	assert.Equal(4, len(words))
+	assert.Equal("new", words[0].Word)
+	assert.Equal(now+60*60, words[0].ExpiresAt)
+	assert.Equal("permanent", words[1].Word)
+	assert.Equal(int64(0), words[1].ExpiresAt)
+	assert.Equal("removed", words[2].Word)
+	assert.Equal(now+60*60, words[2].ExpiresAt)
+	assert.Equal("temporary", words[3].Word)
+	assert.Equal(now+60*60, words[3].ExpiresAt)
+
+	assert.Equal(0, len(db.RemoveExpiredWords()))
+}
+
+func TestExemptions(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+	var otherChatId int64 = 321
+
+	db.UpdateUser(chatId, 1, "ReportBot")
+	assert.Equal(int64(1), db.GetUserIdByName(chatId, "reportbot"))
+	assert.Equal(int64(-1), db.GetUserIdByName(chatId, "unknown"))
+	assert.Equal(int64(-1), db.GetUserIdByName(otherChatId, "ReportBot"))
+
+	db.AddExemption(chatId, Exemption{UserId: 2, Word: "", CategoryId: -1})
+	db.AddExemption(chatId, Exemption{UserId: 1, Word: "error", CategoryId: -1})
+	db.AddExemption(chatId, Exemption{UserId: 1, Word: "", CategoryId: 5})
+	db.AddExemption(chatId, Exemption{UserId: 1, Word: "error", CategoryId: -1})
+	db.AddExemption(otherChatId, Exemption{UserId: 1, Word: "", CategoryId: -1})
+
+	assert.Equal([]Exemption{
+		{UserId: 1, Word: "error", CategoryId: -1},
+		{UserId: 1, Word: "", CategoryId: 5},
+		{UserId: 2, Word: "", CategoryId: -1},
+	}, db.GetExemptions(chatId))
+	assert.Equal([]Exemption{{UserId: 2, Word: "", CategoryId: -1}}, db.GetUserExemptions(chatId, 2))
+
+	db.RemoveExemption(chatId, Exemption{UserId: 1, Word: "error", CategoryId: -1})
+	assert.Equal([]Exemption{{UserId: 1, Word: "", CategoryId: 5}}, db.GetUserExemptions(chatId, 1))
+
+	db.RemoveUserExemptions(chatId, 1)
+	assert.Equal(0, len(db.GetUserExemptions(chatId, 1)))
+	assert.Equal(1, len(db.GetUserExemptions(otherChatId, 1)))
+}
+
+func TestWordsUsageHistory(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+	var userId1 int64 = 1234
+	var userId2 int64 = 4321
+
+	db.UpdateUser(chatId, userId1, "testName1")
+	db.UpdateUser(chatId, userId2, "testName2")
+	db.AddProhibitedWord(chatId, "first", 0)
+	db.AddProhibitedWord(chatId, "second", 0)
+	db.SetProhibitedWordWeight(chatId, "second", 3)
+
+	before := time.Now().Unix()
+	db.AddWordsUsage(chatId, userId1, 10, "first it's", []string{"first"})
+	db.AddWordsUsage(chatId, userId2, 11, "", []string{"first", "second"})
+	after := time.Now().Unix() + 1
+
+	usages := db.GetWordsUsage(chatId, UsageFilter{})
+	assert.Equal(3, len(usages))
+	// the latest uses go first
+	assert.Equal(userId2, usages[0].UserId)
+	assert.Equal("second", usages[0].Word)
+	assert.Equal(3, usages[0].Weight)
+	assert.Equal(int64(11), usages[0].MessageId)
+	assert.Equal("", usages[0].Snippet)
+	assert.Equal("first it's", usages[2].Snippet)
+	assert.Equal(int64(10), usages[2].MessageId)
+	assert.True(usages[2].UsedAt >= before && usages[2].UsedAt < after)
+
+	assert.Equal(3, len(db.GetWordsUsage(chatId, UsageFilter{From: before, To: after})))
+	assert.Equal(0, len(db.GetWordsUsage(chatId, UsageFilter{To: before})))
+	assert.Equal(0, len(db.GetWordsUsage(chatId, UsageFilter{From: after})))
+	assert.Equal(1, len(db.GetWordsUsage(chatId, UsageFilter{UserId: userId1})))
+	assert.Equal(2, len(db.GetWordsUsage(chatId, UsageFilter{Word: "first"})))
+	assert.Equal(1, len(db.GetWordsUsage(chatId, UsageFilter{UserId: userId2, Word: "first"})))
+	assert.Equal(1, len(db.GetWordsUsage(chatId, UsageFilter{Limit: 1})))
+	assert.Equal(0, len(db.GetWordsUsage(321, UsageFilter{})))
+
+	db.RevokeLastUsedWords(chatId, 1, userId1)
+	usages = db.GetWordsUsage(chatId, UsageFilter{UserId: userId2})
+	assert.True(usages[0].IsRevoked)
+	assert.False(usages[1].IsRevoked)
+}
+
+func TestScoresReset(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+	var otherChatId int64 = 321
+	var userId1 int64 = 1234
+	var userId2 int64 = 4321
+
+	db.UpdateUser(chatId, userId1, "testName1")
+	db.UpdateUser(chatId, userId2, "testName2")
+	db.UpdateUser(otherChatId, userId1, "testName1")
+	db.AddProhibitedWord(chatId, "first", 0)
+	db.AddProhibitedWord(chatId, "second", 0)
+	db.SetProhibitedWordWeight(chatId, "second", 3)
+	db.AddProhibitedWord(otherChatId, "first", 0)
+
+	db.AddWordsUsage(chatId, userId1, 0, "", []string{"first", "second"})
+	db.AddWordsUsage(chatId, userId2, 0, "", []string{"first", "first"})
+	db.AddWordsUsage(otherChatId, userId1, 0, "", []string{"first"})
+
+	lastResetId, _ := db.GetLastScoresReset(chatId)
+	assert.Equal(int64(-1), lastResetId)
+
+	// one word
+	resetId := db.ResetScores(chatId, 0, "first")
+	assert.Equal(3, db.GetUserScore(chatId, userId1))
+	assert.Equal(0, db.GetUserScore(chatId, userId2))
+	assert.Equal(1, db.GetUserScore(otherChatId, userId1))
+	assert.Equal(2, len(db.GetWordsUsage(chatId, UsageFilter{UserId: userId2})))
+	assert.True(db.GetWordsUsage(chatId, UsageFilter{UserId: userId2})[0].IsReset)
+	assert.False(db.GetWordsUsage(chatId, UsageFilter{Word: "second"})[0].IsReset)
+
+	lastResetId, createdAt := db.GetLastScoresReset(chatId)
+	assert.Equal(resetId, lastResetId)
+	assert.True(createdAt > 0)
+
+	// reset uses can't be amnestied
+	words, _ := db.RevokeLastUsedWords(chatId, 2, 0)
+	assert.Equal(0, len(words))
+
+	db.AddWordsUsage(chatId, userId2, 0, "", []string{"second"})
+	db.UndoScoresReset(chatId, resetId)
+	assert.Equal(4, db.GetUserScore(chatId, userId1))
+	assert.Equal(5, db.GetUserScore(chatId, userId2))
+	assert.False(db.GetWordsUsage(chatId, UsageFilter{UserId: userId2})[1].IsReset)
+	lastResetId, _ = db.GetLastScoresReset(chatId)
+	assert.Equal(int64(-1), lastResetId)
+
+	// one user
+	db.ResetScores(chatId, userId1, "")
+	assert.Equal(0, db.GetUserScore(chatId, userId1))
+	assert.Equal(5, db.GetUserScore(chatId, userId2))
+	assert.Equal(1, db.GetUserScore(otherChatId, userId1))
+
+	// everyone
+	resetId = db.ResetScores(chatId, 0, "")
+	assert.Equal(0, db.GetUserScore(chatId, userId1))
+	assert.Equal(0, db.GetUserScore(chatId, userId2))
+	assert.Equal(1, db.GetUserScore(otherChatId, userId1))
+	for _, usage := range db.GetWordsUsage(chatId, UsageFilter{}) {
+		assert.True(usage.IsReset)
+	}
+
+	db.UndoScoresReset(chatId, resetId)
+	assert.Equal(0, db.GetUserScore(chatId, userId1))
+	assert.Equal(5, db.GetUserScore(chatId, userId2))
+}
+
+func TestUsageStatistics(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+	var userId1 int64 = 1234
+	var userId2 int64 = 4321
+
+	assert.Equal(UsageCounts{}, db.GetUsageCounts(chatId, 0))
+	assert.Equal(0, len(db.GetTopWords(chatId, 0, 10)))
+
+	db.UpdateUser(chatId, userId1, "testName1")
+	db.UpdateUser(chatId, userId2, "testName2")
+	db.AddProhibitedWord(chatId, "first", 0)
+	db.AddProhibitedWord(chatId, "second", 0)
+	db.AddProhibitedWord(chatId, "third", 0)
+
+	before := time.Now().Unix()
+	db.AddWordsUsage(chatId, userId1, 0, "", []string{"first", "second", "second"})
+	db.AddWordsUsage(chatId, userId2, 0, "", []string{"first", "third", "third"})
+	db.RevokeLastUsedWords(chatId, 1, userId1)
+
+	assert.Equal(UsageCounts{Count: 5, RevokedCount: 1}, db.GetUsageCounts(chatId, 0))
+	assert.Equal(UsageCounts{Count: 5, RevokedCount: 1}, db.GetUsageCounts(chatId, before))
+	assert.Equal(UsageCounts{}, db.GetUsageCounts(chatId, time.Now().Unix()+1))
+	assert.Equal(UsageCounts{}, db.GetUsageCounts(321, 0))
+
+	assert.Equal([]WordCount{{Word: "first", Count: 2}, {Word: "second", Count: 2}, {Word: "third", Count: 1}}, db.GetTopWords(chatId, 0, 10))
+	assert.Equal([]WordCount{{Word: "first", Count: 2}}, db.GetTopWords(chatId, 0, 1))
+	assert.Equal([]WordCount{{Word: "second", Count: 2}, {Word: "first", Count: 1}}, db.GetTopWords(chatId, userId1, 10))
+	assert.Equal([]WordCount{{Word: "first", Count: 1}, {Word: "third", Count: 1}}, db.GetTopWords(chatId, userId2, 10))
+}
+
+func TestAbsentUsers(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+	var otherChatId int64 = 321
+	var userId1 int64 = 1234
+	var userId2 int64 = 4321
+
+	db.UpdateUser(chatId, userId1, "testName1")
+	db.UpdateUser(chatId, userId2, "testName2")
+	db.UpdateUser(otherChatId, userId1, "testName1")
+	db.AddProhibitedWord(chatId, "word", 0)
+	categoryId := db.GetOrCreateCategory(chatId, "category")
+	db.SetProhibitedWordCategory(chatId, "word", categoryId)
+	db.AddWordsUsage(chatId, userId1, 0, "", []string{"word", "word"})
+	db.AddWordsUsage(chatId, userId2, 0, "", []string{"word"})
+	db.AddProhibitedWord(otherChatId, "word", 0)
+	db.AddWordsUsage(otherChatId, userId1, 0, "", []string{"word"})
+
+	db.SetUserAbsent(chatId, userId1, true)
+
+	ids, _, _ := db.GetUsersList(chatId, false)
+	assert.Equal([]int64{userId2}, ids)
+	ids, _, _ = db.GetUsersList(chatId, true)
+	assert.Equal([]int64{userId1, userId2}, ids)
+	ids, _, _ = db.GetCategoryUsersList(chatId, categoryId, false)
+	assert.Equal([]int64{userId2}, ids)
+	ids, _, _ = db.GetCategoryUsersList(chatId, categoryId, true)
+	assert.Equal([]int64{userId1, userId2}, ids)
+	ids, _, _ = db.GetUsersList(otherChatId, false)
+	assert.Equal([]int64{userId1}, ids)
+
+	// the score is kept while the user is away
+	assert.Equal(2, db.GetUserScore(chatId, userId1))
+
+	db.SetUserAbsent(chatId, userId1, false)
+	ids, _, _ = db.GetUsersList(chatId, false)
+	assert.Equal([]int64{userId1, userId2}, ids)
+
+	// nothing to hide for users without fines
+	var userId3 int64 = 5678
+	db.SetUserAbsent(chatId, userId3, true)
+	ids, _, _ = db.GetUsersList(chatId, true)
+	assert.Equal([]int64{userId1, userId2}, ids)
+}
+
+func TestOutdatedMessageRecords(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+	now := time.Now().Unix()
+
+	db.AddMessageWords(chatId, 1, []string{"word"})
+	db.AddFuzzyHit(chatId, FuzzyHit{UserId: 1, Word: "word", Surface: "wrd", Distance: 1})
+
+	db.RemoveMessageWordsBefore(now - 60)
+	db.RemoveFuzzyHitsBefore(now - 60)
+	assert.Equal([]string{"word"}, db.GetMessageWords(chatId, 1))
+	assert.Equal(1, len(db.GetLastFuzzyHits(chatId, 10)))
+
+	db.RemoveMessageWordsBefore(now + 60)
+	db.RemoveFuzzyHitsBefore(now + 60)
+	assert.Equal(0, len(db.GetMessageWords(chatId, 1)))
+	assert.Equal(0, len(db.GetLastFuzzyHits(chatId, 10)))
+}
+
+func TestNotFinedUsersAreNotListed(t *testing.T) {
+	assert := require.New(t)
+	db := createDbAndConnect(t)
+	defer clearDb()
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	var chatId int64 = 123
+	var finedUserId int64 = 1234
+	var exemptedUserId int64 = 4321
+
+	db.AddProhibitedWord(chatId, "word", 0)
+	db.UpdateUser(chatId, finedUserId, "fined")
+	db.AddWordsUsage(chatId, finedUserId, 0, "", []string{"word"})
+	db.UpdateUser(chatId, exemptedUserId, "exempted")
+	db.AddExemption(chatId, Exemption{UserId: exemptedUserId, CategoryId: -1})
+
+	ids, _, _ := db.GetUsersList(chatId, true)
+	assert.Equal([]int64{finedUserId}, ids)
+	assert.Equal(exemptedUserId, db.GetUserIdByName(chatId, "exempted"))
+
+	// users with reset scores are still listed
+	db.ResetScores(chatId, 0, "")
+	ids, _, scores := db.GetUsersList(chatId, true)
+	assert.Equal([]int64{finedUserId}, ids)
+	assert.Equal([]int{0}, scores)
+}
+
+// makes a database of an old version the same way old versions of the bot did
+func createOldDatabase(t *testing.T, version string, tables []string) {
+	assert := require.New(t)
+	clearDb()
+
+	conn, err := sql.Open("sqlite3", testDbPath)
+	assert.NoError(err)
+
+	db := &Database{conn: conn}
+	db.execQuery("CREATE TABLE global_vars(name TEXT NOT NULL PRIMARY KEY, integer_value INTEGER, string_value STRING)")
+	for _, table := range tables {
+		db.execQuery("CREATE TABLE " + table)
+	}
+	db.SetDatabaseVersion(version)
+	db.Disconnect()
+}
+
+// exercises the columns that were added by the updaters
+func checkUpdatedDatabase(t *testing.T, db *Database) {
+	assert := require.New(t)
+
+	assert.Equal(latestVersion, db.GetDatabaseVersion())
+
+	var chatId int64 = 123
+	var userId int64 = 1234
+	now := time.Now().Unix()
+
+	categoryId := db.GetOrCreateCategory(chatId, "category")
+	db.SetCategorySchedule(categoryId, "sat")
+	db.AddTemporaryProhibitedWord(chatId, "word", 0, now+60)
+	db.SetProhibitedWordCategory(chatId, "word", categoryId)
+	db.UpdateUser(chatId, userId, "user")
+	db.AddWordsUsage(chatId, userId, 1, "word", []string{"word"})
+	db.SetUserAbsent(chatId, userId, false)
+	db.AddMessageWords(chatId, 1, []string{"word"})
+	db.AddFuzzyHit(chatId, FuzzyHit{UserId: userId, Word: "word", Surface: "wrd", Distance: 1})
+	db.RemoveMessageWordsBefore(now - 60)
+	db.RemoveFuzzyHitsBefore(now - 60)
+
+	words := db.GetProhibitedWordsData(chatId)
+	assert.Equal(1, len(words))
+	assert.Equal("sat", words[0].Schedule)
+	assert.Equal(now+60, words[0].ExpiresAt)
+	ids, _, _ := db.GetUsersList(chatId, false)
+	assert.Equal([]int64{userId}, ids)
+	assert.Equal([]string{"word"}, db.GetMessageWords(chatId, 1))
+	assert.Equal(1, len(db.GetLastFuzzyHits(chatId, 10)))
+}
+
+func TestUpdateFromVersionWithoutMessageWords(t *testing.T) {
+	// message_words and fuzzy_hits are created on connection with all their columns
+	createOldDatabase(t, "1.5", []string{
+		"users(messenger_id INTEGER NOT NULL, chat_id INTEGER NOT NULL, score INTEGER NOT NULL, name STRING NOT NULL, PRIMARY KEY (messenger_id, chat_id))",
+		"prohibited_words(id INTEGER NOT NULL PRIMARY KEY, chat_id INTEGER NOT NULL, word STRING NOT NULL, removed INTEGER, pattern_type INTEGER, stemming INTEGER, weight INTEGER, category_id INTEGER, UNIQUE(chat_id, word))",
+		"word_categories(id INTEGER NOT NULL PRIMARY KEY, chat_id INTEGER NOT NULL, name STRING NOT NULL, disabled INTEGER, weight INTEGER, UNIQUE(chat_id, name))",
+		"used_words(id INTEGER NOT NULL PRIMARY KEY, user_id INTEGER NOT NULL, chat_id INTEGER NOT NULL, word_id INTEGER NOT NULL, revoked INTEGER, weight INTEGER)",
+	})
+	defer clearDb()
+
+	db := connectDb(t)
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	UpdateVersion(db)
+	checkUpdatedDatabase(t, db)
+}
+
+func TestUpdateFromFirstVersion(t *testing.T) {
+	// word_categories is created on connection with all its columns
+	createOldDatabase(t, "1.1", []string{
+		"users(messenger_id INTEGER NOT NULL, chat_id INTEGER NOT NULL, score INTEGER NOT NULL, name STRING NOT NULL, PRIMARY KEY (messenger_id, chat_id))",
+		"prohibited_words(id INTEGER NOT NULL PRIMARY KEY, chat_id INTEGER NOT NULL, word STRING NOT NULL, removed INTEGER, UNIQUE(chat_id, word))",
+		"used_words(id INTEGER NOT NULL PRIMARY KEY, user_id INTEGER NOT NULL, chat_id INTEGER NOT NULL, word_id INTEGER NOT NULL, revoked INTEGER)",
+	})
+	defer clearDb()
+
+	db := connectDb(t)
+	if db == nil {
+		t.Fail()
+		return
+	}
+	defer db.Disconnect()
+
+	UpdateVersion(db)
+	checkUpdatedDatabase(t, db)
+}
@@ -1,12 +1,13 @@
 package database
 
 import (
+	"fmt"
 	"log"
 )
 
 const (
 	minimalVersion = "1.0"
-	latestVersion  = "1.1"
+	latestVersion  = "1.14"
 )
 
 type dbUpdater struct {
@@ -29,6 +30,40 @@ func UpdateVersion(db *Database) {
 	db.SetDatabaseVersion(latestVersion)
 }
 
+func hasColumn(db *Database, table string, column string) bool {
+	rows, err := db.conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		var columnType string
+		var notNull int
+		var defaultValue interface{}
+		var primaryKey int
+		err := rows.Scan(&id, &name, &columnType, &notNull, &defaultValue, &primaryKey)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
+// tables created on connection already have all the columns,
+// only tables that existed before the column was introduced need it to be added
+func addColumnIfMissing(db *Database, table string, column string, columnType string) {
+	if !hasColumn(db, table, column) {
+		db.execQuery(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, columnType))
+	}
+}
+
 func makeUpdaters(versionFrom string, versionTo string) (updaters []dbUpdater) {
 	allUpdaters := makeAllUpdaters()
 
@@ -41,8 +76,8 @@ func makeUpdaters(versionFrom string, versionTo string) (updaters []dbUpdater) {
 			}
 		} else {
 			if updater.version == versionFrom {
+				// the current version is already applied, start from the next one
 				isFirstFound = true
-				updaters = append(updaters, updater)
 			}
 		}
 	}
@@ -58,6 +93,94 @@ func makeAllUpdaters() (updaters []dbUpdater) {
 				db.execQuery("ALTER TABLE prohibited_words ADD COLUMN removed INTEGER")
 			},
 		},
+		dbUpdater{
+			version: "1.2",
+			updateDb: func(db *Database) {
+				db.execQuery("ALTER TABLE prohibited_words ADD COLUMN pattern_type INTEGER")
+			},
+		},
+		dbUpdater{
+			// chat_settings table is created on connection
+			version: "1.3",
+			updateDb: func(db *Database) {
+				db.execQuery("ALTER TABLE prohibited_words ADD COLUMN stemming INTEGER")
+			},
+		},
+		dbUpdater{
+			version: "1.4",
+			updateDb: func(db *Database) {
+				db.execQuery("ALTER TABLE prohibited_words ADD COLUMN weight INTEGER")
+				db.execQuery("ALTER TABLE used_words ADD COLUMN weight INTEGER")
+			},
+		},
+		dbUpdater{
+			// word_categories table is created on connection
+			version: "1.5",
+			updateDb: func(db *Database) {
+				db.execQuery("ALTER TABLE prohibited_words ADD COLUMN category_id INTEGER")
+			},
+		},
+		dbUpdater{
+			// fuzzy_hits table is created on connection
+			version: "1.6",
+			updateDb: func(db *Database) {
+				db.execQuery("ALTER TABLE prohibited_words ADD COLUMN fuzzy INTEGER")
+			},
+		},
+		dbUpdater{
+			version: "1.7",
+			updateDb: func(db *Database) {
+				db.execQuery("ALTER TABLE prohibited_words ADD COLUMN kind INTEGER")
+			},
+		},
+		dbUpdater{
+			version: "1.8",
+			updateDb: func(db *Database) {
+				db.execQuery("ALTER TABLE prohibited_words ADD COLUMN options INTEGER")
+			},
+		},
+		dbUpdater{
+			version: "1.9",
+			updateDb: func(db *Database) {
+				db.execQuery("ALTER TABLE prohibited_words ADD COLUMN schedule STRING")
+				addColumnIfMissing(db, "word_categories", "schedule", "STRING")
+			},
+		},
+		dbUpdater{
+			version: "1.10",
+			updateDb: func(db *Database) {
+				db.execQuery("ALTER TABLE prohibited_words ADD COLUMN expires_at INTEGER")
+			},
+		},
+		dbUpdater{
+			// old uses stay without time and message
+			version: "1.11",
+			updateDb: func(db *Database) {
+				db.execQuery("ALTER TABLE used_words ADD COLUMN used_at INTEGER")
+				db.execQuery("ALTER TABLE used_words ADD COLUMN message_id INTEGER")
+				db.execQuery("ALTER TABLE used_words ADD COLUMN snippet STRING")
+			},
+		},
+		dbUpdater{
+			// score_resets and score_reset_users tables are created on connection
+			version: "1.12",
+			updateDb: func(db *Database) {
+				db.execQuery("ALTER TABLE used_words ADD COLUMN reset_id INTEGER")
+			},
+		},
+		dbUpdater{
+			version: "1.13",
+			updateDb: func(db *Database) {
+				db.execQuery("ALTER TABLE users ADD COLUMN absent INTEGER")
+			},
+		},
+		dbUpdater{
+			version: "1.14",
+			updateDb: func(db *Database) {
+				addColumnIfMissing(db, "message_words", "created_at", "INTEGER")
+				addColumnIfMissing(db, "fuzzy_hits", "created_at", "INTEGER")
+			},
+		},
 	}
 	return
 }
@@ -5,16 +5,23 @@ import (
 	"github.com/gameraccoon/telegram-prohibited-words-bot/database"
 	"github.com/gameraccoon/telegram-prohibited-words-bot/processing"
 	"github.com/gameraccoon/telegram-prohibited-words-bot/telegramChat"
-	"github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/nicksnyder/go-i18n/i18n"
 	"io/ioutil"
 	"log"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 func init() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	i18n.MustLoadTranslationFile("./data/strings/ru-ru.all.json")
+	translationFiles, err := filepath.Glob("./data/strings/*.all.json")
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	for _, translationFile := range translationFiles {
+		i18n.MustLoadTranslationFile(translationFile)
+	}
 }
 
 func getFileStringContent(filePath string) (content string, err error) {
@@ -38,25 +45,31 @@ func getApiToken() (token string, err error) {
 	return getFileStringContent("./telegramApiToken.txt")
 }
 
-func updateBot(bot *tgbotapi.BotAPI, staticData *processing.StaticProccessStructs) {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-
-	updates, err := bot.GetUpdatesChan(u)
-
-	if err != nil {
-		log.Fatal(err.Error())
-	}
+func updateBot(chat *telegramChat.TelegramChat, staticData *processing.StaticProccessStructs) {
+	updates := chat.GetUpdatesChan(60)
 
 	processors := Processors{
 		Main: makeUserCommandProcessors(),
 	}
 
-	for update := range updates {
-		if update.Message == nil {
-			continue
+	// temporary words are checked between updates, so the cache is never used concurrently
+	expirationTicker := time.NewTicker(time.Minute)
+	defer expirationTicker.Stop()
+
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			if update.Message == nil && update.EditedMessage == nil && update.ChatMember == nil {
+				continue
+			}
+			processUpdate(&update, staticData, &processors)
+		case <-expirationTicker.C:
+			processExpiredWords(staticData)
+			removeOutdatedRecords(staticData)
 		}
-		processUpdate(&update, staticData, &processors)
 	}
 }
 
@@ -96,12 +109,13 @@ func main() {
 	chat.SetDebugModeEnabled(config.ExtendedLog)
 
 	staticData := &processing.StaticProccessStructs{
-		Config:      &config,
-		Chat:        chat,
-		Db:          db,
-		Trans:       trans,
-		CachedWords: map[int64][]string{},
+		Config:        &config,
+		Chat:          chat,
+		Db:            db,
+		Trans:         trans,
+		CachedWords:   map[int64]*processing.ChatWords{},
+		PendingResets: map[int64]*processing.ScoresReset{},
 	}
 
-	updateBot(chat.GetBot(), staticData)
+	updateBot(chat, staticData)
 }
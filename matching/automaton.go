@@ -0,0 +1,108 @@
+package matching
+
+// Aho-Corasick automaton over token sequences
+// it finds all occurrences of all the sequences in one pass over the tokens
+type automaton struct {
+	nodes []automatonNode
+	// length of every added sequence
+	sequenceLengths []int
+}
+
+type automatonNode struct {
+	next map[string]int
+	// the longest proper suffix of this node that is also a node
+	fail int
+	// sequences that end in this node or in any of its fail nodes
+	outputs []int
+}
+
+type automatonMatch struct {
+	sequenceIdx int
+	start       int
+	length      int
+}
+
+func makeAutomaton() *automaton {
+	return &automaton{
+		nodes: []automatonNode{{next: map[string]int{}}},
+	}
+}
+
+// returns index of the sequence that is used in the matches
+func (automaton *automaton) addSequence(symbols []string) int {
+	node := 0
+	for _, symbol := range symbols {
+		nextNode, ok := automaton.nodes[node].next[symbol]
+		if !ok {
+			nextNode = len(automaton.nodes)
+			automaton.nodes = append(automaton.nodes, automatonNode{next: map[string]int{}})
+			automaton.nodes[node].next[symbol] = nextNode
+		}
+		node = nextNode
+	}
+
+	sequenceIdx := len(automaton.sequenceLengths)
+	automaton.sequenceLengths = append(automaton.sequenceLengths, len(symbols))
+	automaton.nodes[node].outputs = append(automaton.nodes[node].outputs, sequenceIdx)
+	return sequenceIdx
+}
+
+// should be called after all the sequences are added
+func (automaton *automaton) build() {
+	queue := []int{}
+	for _, child := range automaton.nodes[0].next {
+		automaton.nodes[child].fail = 0
+		queue = append(queue, child)
+	}
+
+	// breadth-first so fail nodes are always processed before the nodes that refer to them
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		for symbol, child := range automaton.nodes[node].next {
+			fail := automaton.nodes[node].fail
+			for {
+				if failChild, ok := automaton.nodes[fail].next[symbol]; ok && failChild != child {
+					automaton.nodes[child].fail = failChild
+					break
+				}
+				if fail == 0 {
+					automaton.nodes[child].fail = 0
+					break
+				}
+				fail = automaton.nodes[fail].fail
+			}
+
+			failOutputs := automaton.nodes[automaton.nodes[child].fail].outputs
+			automaton.nodes[child].outputs = append(automaton.nodes[child].outputs, failOutputs...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+func (automaton *automaton) findAll(symbols []string) (matches []automatonMatch) {
+	node := 0
+	for position, symbol := range symbols {
+		for {
+			if nextNode, ok := automaton.nodes[node].next[symbol]; ok {
+				node = nextNode
+				break
+			}
+			if node == 0 {
+				break
+			}
+			node = automaton.nodes[node].fail
+		}
+
+		for _, sequenceIdx := range automaton.nodes[node].outputs {
+			length := automaton.sequenceLengths[sequenceIdx]
+			matches = append(matches, automatonMatch{
+				sequenceIdx: sequenceIdx,
+				start:       position - length + 1,
+				length:      length,
+			})
+		}
+	}
+	return
+}
@@ -0,0 +1,147 @@
+package matching
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// parameters of punycode, RFC 3492
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+	punycodePrefix      = "xn--"
+)
+
+func adaptPunycodeBias(delta int, pointsCount int, isFirstTime bool) int {
+	if isFirstTime {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / pointsCount
+
+	k := 0
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
+
+func decodePunycodeDigit(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c-'0') + 26
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a')
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A')
+	default:
+		return -1
+	}
+}
+
+// decodes a single label without the "xn--" prefix
+func decodePunycode(input string) (string, error) {
+	output := []rune{}
+	if basicEnd := strings.LastIndex(input, "-"); basicEnd != -1 {
+		output = []rune(input[:basicEnd])
+		input = input[basicEnd+1:]
+	}
+
+	n := punycodeInitialN
+	i := 0
+	bias := punycodeInitialBias
+
+	for position := 0; position < len(input); {
+		oldI := i
+		weight := 1
+		for k := punycodeBase; ; k += punycodeBase {
+			if position >= len(input) {
+				return "", fmt.Errorf("unexpected end of punycode")
+			}
+
+			digit := decodePunycodeDigit(input[position])
+			position++
+			if digit < 0 {
+				return "", fmt.Errorf("wrong punycode digit")
+			}
+
+			i += digit * weight
+			if i < 0 {
+				return "", fmt.Errorf("punycode overflow")
+			}
+
+			threshold := k - bias
+			if threshold < punycodeTMin {
+				threshold = punycodeTMin
+			} else if threshold > punycodeTMax {
+				threshold = punycodeTMax
+			}
+
+			if digit < threshold {
+				break
+			}
+			weight *= punycodeBase - threshold
+		}
+
+		bias = adaptPunycodeBias(i-oldI, len(output)+1, oldI == 0)
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+
+		output = append(output[:i], append([]rune{rune(n)}, output[i:]...)...)
+		i++
+	}
+
+	return string(output), nil
+}
+
+// NormalizeDomain returns a lowercase domain with punycode labels decoded and without "www."
+func NormalizeDomain(domain string) string {
+	labels := strings.Split(strings.Trim(strings.ToLower(domain), "."), ".")
+	for i, label := range labels {
+		if strings.HasPrefix(label, punycodePrefix) {
+			// broken labels are kept as they are
+			if decodedLabel, err := decodePunycode(label[len(punycodePrefix):]); err == nil {
+				labels[i] = decodedLabel
+			}
+		}
+	}
+
+	if len(labels) > 2 && labels[0] == "www" {
+		labels = labels[1:]
+	}
+
+	return strings.Join(labels, ".")
+}
+
+// GetLinkDomain returns the normalized domain of a link written with or without a scheme,
+// an empty string if there is no domain
+func GetLinkDomain(link string) string {
+	link = strings.TrimSpace(link)
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+
+	parsedUrl, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+
+	domain := NormalizeDomain(parsedUrl.Hostname())
+	if !strings.Contains(domain, ".") {
+		return ""
+	}
+	return domain
+}
+
+// IsSubdomain tells if the domain is the prohibited domain or one of its subdomains
+func IsSubdomain(domain string, prohibitedDomain string) bool {
+	return domain == prohibitedDomain || strings.HasSuffix(domain, "."+prohibitedDomain)
+}
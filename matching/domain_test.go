@@ -0,0 +1,37 @@
+package matching
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal("example.com", NormalizeDomain("Example.COM."))
+	assert.Equal("example.com", NormalizeDomain("www.example.com"))
+	assert.Equal("www.com", NormalizeDomain("www.com"))
+	assert.Equal("пример.рф", NormalizeDomain("xn--e1afmkfd.xn--p1ai"))
+	assert.Equal("яндекс.рф", NormalizeDomain("XN--D1ACPJX3F.xn--p1ai"))
+	assert.Equal("bücher.de", NormalizeDomain("xn--bcher-kva.de"))
+	assert.Equal("правительство.рф", NormalizeDomain("www.xn--80aealotwbjpid2k.xn--p1ai"))
+	assert.Equal("пример.рф", NormalizeDomain("Пример.РФ"))
+	// broken punycode is kept as it is
+	assert.Equal("xn--!!!.com", NormalizeDomain("xn--!!!.com"))
+}
+
+func TestLinkDomain(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal("example.com", GetLinkDomain("https://www.example.com/path?query=1"))
+	assert.Equal("sub.example.com", GetLinkDomain("sub.example.com/path"))
+	assert.Equal("example.com", GetLinkDomain("http://user@example.com:8080"))
+	assert.Equal("пример.рф", GetLinkDomain("https://xn--e1afmkfd.xn--p1ai/"))
+	assert.Equal("", GetLinkDomain("localhost"))
+	assert.Equal("", GetLinkDomain(""))
+
+	assert.True(IsSubdomain("example.com", "example.com"))
+	assert.True(IsSubdomain("sub.example.com", "example.com"))
+	assert.False(IsSubdomain("notexample.com", "example.com"))
+	assert.False(IsSubdomain("example.com", "sub.example.com"))
+}
@@ -0,0 +1,93 @@
+package matching
+
+import (
+	"strings"
+)
+
+const (
+	zeroWidthJoiner    = '\u200d'
+	variationSelector  = '\ufe0f'
+	combiningKeycap    = '\u20e3'
+	skinToneFirst      = '\U0001f3fb'
+	skinToneLast       = '\U0001f3ff'
+	regionalFirst      = '\U0001f1e6'
+	regionalLast       = '\U0001f1ff'
+	tagFirst           = '\U000e0020'
+	tagLast            = '\U000e007f'
+	pictographicsFirst = '\U0001f000'
+	pictographicsLast  = '\U0001faff'
+)
+
+func isPictographic(r rune) bool {
+	return (r >= pictographicsFirst && r <= pictographicsLast) ||
+		(r >= 0x2600 && r <= 0x27bf) ||
+		(r >= 0x2300 && r <= 0x23ff) ||
+		(r >= 0x2b00 && r <= 0x2bff)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= regionalFirst && r <= regionalLast
+}
+
+// parts of an emoji that follow its base character
+func isEmojiModifier(r rune) bool {
+	return r == variationSelector || r == combiningKeycap || (r >= skinToneFirst && r <= skinToneLast) || (r >= tagFirst && r <= tagLast)
+}
+
+// SplitEmoji returns emoji of the text, each one as a whole grapheme cluster:
+// with skin tones, joined sequences ("👨‍👩‍👧"), flags and keycaps
+func SplitEmoji(text string) (emoji []string) {
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		start := i
+
+		switch {
+		case isRegionalIndicator(runes[i]):
+			i++
+			// flags are pairs of regional indicators
+			if i < len(runes) && isRegionalIndicator(runes[i]) {
+				i++
+			}
+		case isPictographic(runes[i]):
+			i++
+			for i < len(runes) {
+				if isEmojiModifier(runes[i]) {
+					i++
+				} else if runes[i] == zeroWidthJoiner && i+1 < len(runes) && isPictographic(runes[i+1]) {
+					i += 2
+				} else {
+					break
+				}
+			}
+		case strings.ContainsRune("0123456789#*", runes[i]) && i+1 < len(runes) && (runes[i+1] == combiningKeycap || (runes[i+1] == variationSelector && i+2 < len(runes) && runes[i+2] == combiningKeycap)):
+			// keycaps like "1️⃣"
+			i++
+			for i < len(runes) && isEmojiModifier(runes[i]) {
+				i++
+			}
+		default:
+			i++
+			continue
+		}
+
+		emoji = append(emoji, string(runes[start:i]))
+	}
+	return
+}
+
+// NormalizeEmoji removes the parts that don't change the meaning of an emoji:
+// variation selectors and skin tones
+func NormalizeEmoji(emoji string) string {
+	return strings.Map(func(r rune) rune {
+		if r == variationSelector || (r >= skinToneFirst && r <= skinToneLast) {
+			return -1
+		}
+		return r
+	}, emoji)
+}
+
+// IsEmoji tells if the text is a single emoji
+func IsEmoji(text string) bool {
+	emoji := SplitEmoji(text)
+	return len(emoji) == 1 && emoji[0] == text
+}
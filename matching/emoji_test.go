@@ -0,0 +1,35 @@
+package matching
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSplitEmoji(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal([]string{"🤡", "💩"}, SplitEmoji("ты 🤡, а это 💩"))
+	assert.Equal([]string{"🤡", "🤡"}, SplitEmoji("🤡🤡"))
+	// skin tones and variation selectors are parts of the emoji
+	assert.Equal([]string{"👍🏽", "❤\ufe0f"}, SplitEmoji("👍🏽❤\ufe0f"))
+	// joined sequences are a single emoji
+	assert.Equal([]string{"👨\u200d👩\u200d👧"}, SplitEmoji("👨\u200d👩\u200d👧"))
+	// flags are pairs of regional indicators
+	assert.Equal([]string{"🇷🇺", "🇺🇦"}, SplitEmoji("🇷🇺🇺🇦"))
+	assert.Equal([]string{"1\ufe0f\u20e3"}, SplitEmoji("1\ufe0f\u20e3 and 1"))
+	assert.Equal(0, len(SplitEmoji("просто текст 123")))
+}
+
+func TestNormalizeEmoji(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal("👍", NormalizeEmoji("👍🏽"))
+	assert.Equal("❤", NormalizeEmoji("❤\ufe0f"))
+	assert.Equal("👨\u200d👩\u200d👧", NormalizeEmoji("👨\u200d👩\u200d👧"))
+
+	assert.True(IsEmoji("🤡"))
+	assert.True(IsEmoji("👍🏽"))
+	assert.False(IsEmoji("🤡🤡"))
+	assert.False(IsEmoji("🤡 клоун"))
+	assert.False(IsEmoji("клоун"))
+}
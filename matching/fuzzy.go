@@ -0,0 +1,93 @@
+package matching
+
+// words shorter than this are never matched fuzzily, too many words are close to them
+const minFuzzyWordLength = 4
+
+// matches tokens that are close to the word but not equal to it,
+// equal tokens are left for the exact or stemming matchers of the same word
+type fuzzyMatcher struct {
+	word           string
+	normalizedWord []rune
+	maxDistance    int
+}
+
+// DamerauLevenshteinDistance counts insertions, deletions, substitutions
+// and transpositions of adjacent letters needed to turn one string into another
+func DamerauLevenshteinDistance(first string, second string) int {
+	a := []rune(first)
+	b := []rune(second)
+
+	distances := make([][]int, len(a)+1)
+	for i := range distances {
+		distances[i] = make([]int, len(b)+1)
+		distances[i][0] = i
+	}
+	for j := range distances[0] {
+		distances[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			distances[i][j] = minInt(minInt(distances[i-1][j]+1, distances[i][j-1]+1), distances[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				distances[i][j] = minInt(distances[i][j], distances[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return distances[len(a)][len(b)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// GetFuzzyDistance limits the configured distance for short words, one error for every three letters
+func GetFuzzyDistance(maxDistance int, wordLength int) int {
+	if wordLength < minFuzzyWordLength {
+		return 0
+	}
+	return minInt(maxDistance, wordLength/3)
+}
+
+// MakeFuzzyMatcher returns nil if the word is too short to be matched fuzzily
+func MakeFuzzyMatcher(word string, maxDistance int, normalizer Normalizer) Matcher {
+	normalizedWord := []rune(normalizer.NormalizeToken(word))
+	distance := GetFuzzyDistance(maxDistance, len(normalizedWord))
+	if distance <= 0 {
+		return nil
+	}
+	return &fuzzyMatcher{word: word, normalizedWord: normalizedWord, maxDistance: distance}
+}
+
+func (matcher *fuzzyMatcher) GetWord() string {
+	return matcher.word
+}
+
+func (matcher *fuzzyMatcher) GetPatternType() PatternType {
+	return ExactPattern
+}
+
+func (matcher *fuzzyMatcher) getDistance(token Token) int {
+	normalizedToken := []rune(token.Normalized)
+	// the distance can't be less than the difference in length
+	lengthDifference := len(normalizedToken) - len(matcher.normalizedWord)
+	if lengthDifference > matcher.maxDistance || -lengthDifference > matcher.maxDistance {
+		return matcher.maxDistance + 1
+	}
+	return DamerauLevenshteinDistance(token.Normalized, string(matcher.normalizedWord))
+}
+
+func (matcher *fuzzyMatcher) Match(tokens []Token, position int) int {
+	distance := matcher.getDistance(tokens[position])
+	return matchedTokenCount(distance > 0 && distance <= matcher.maxDistance)
+}
@@ -0,0 +1,53 @@
+package matching
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDamerauLevenshteinDistance(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(0, DamerauLevenshteinDistance("fuck", "fuck"))
+	assert.Equal(1, DamerauLevenshteinDistance("fcuk", "fuck"))
+	assert.Equal(1, DamerauLevenshteinDistance("fuk", "fuck"))
+	assert.Equal(1, DamerauLevenshteinDistance("fucck", "fuck"))
+	assert.Equal(1, DamerauLevenshteinDistance("блят", "блять"))
+	assert.Equal(2, DamerauLevenshteinDistance("бляяя", "блять"))
+	assert.Equal(5, DamerauLevenshteinDistance("", "слово"))
+	assert.Equal(3, DamerauLevenshteinDistance("kitten", "sitting"))
+}
+
+func TestFuzzyDistance(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(0, GetFuzzyDistance(2, 3))
+	assert.Equal(1, GetFuzzyDistance(2, 4))
+	assert.Equal(1, GetFuzzyDistance(2, 5))
+	assert.Equal(2, GetFuzzyDistance(2, 6))
+	assert.Equal(2, GetFuzzyDistance(2, 12))
+	assert.Equal(1, GetFuzzyDistance(1, 12))
+
+	assert.Nil(MakeFuzzyMatcher("бля", 2, Normalizer{}))
+	assert.NotNil(MakeFuzzyMatcher("fuck", 2, Normalizer{}))
+}
+
+func TestFuzzyMatcher(t *testing.T) {
+	assert := require.New(t)
+
+	exactMatcher, err := MakeMatcher(ExactPattern, "fuck", Normalizer{})
+	assert.NoError(err)
+
+	scanner := MakeScanner([]Matcher{
+		exactMatcher,
+		MakeFuzzyMatcher("fuck", 2, Normalizer{}),
+		MakeFuzzyMatcher("сволочь", 2, Normalizer{}),
+	})
+
+	assert.Equal([]WordMatch{
+		{Word: "fuck", Surface: "fcuk", Distance: 1},
+		{Word: "fuck", Surface: "Fuck"},
+		{Word: "сволочь", Surface: "сволачь", Distance: 1},
+		{Word: "сволочь", Surface: "свалачь", Distance: 2},
+	}, scanner.FindMatches(makeTestTokens("fcuk Fuck fucking сволачь свалачь сволочами")))
+}
@@ -0,0 +1,310 @@
+package matching
+
+import (
+	"github.com/gameraccoon/telegram-prohibited-words-bot/stemming"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type PatternType int
+
+const (
+	ExactPattern PatternType = iota
+	GlobPattern
+	RegexPattern
+)
+
+const (
+	globPrefix  = "glob:"
+	regexPrefix = "re:"
+)
+
+// WordMatch is a single usage of a prohibited entry in a message
+type WordMatch struct {
+	// the prohibited entry as it is stored
+	Word string
+	// the form that was used in the message
+	Surface string
+	// edit distance between the form and the entry for fuzzy matches, zero for others
+	Distance int
+}
+
+// Token is a single word of a message
+type Token struct {
+	// the token as it was written
+	Text string
+	// the token after normalization
+	Normalized string
+	// the token after normalization but with its case kept, for case-sensitive entries
+	NormalizedWithCase string
+	// characters between the previous token and this one
+	Separator string
+}
+
+// IsTokenRune tells if a character is a part of a word, all other characters separate words
+func IsTokenRune(r rune) bool {
+	// combining marks stay with their letters when they are not stripped
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.M, r)
+}
+
+// MakeToken prepares a token to be checked by matchers
+func MakeToken(text string, normalizer Normalizer) Token {
+	return Token{
+		Text:               text,
+		Normalized:         normalizer.NormalizeToken(text),
+		NormalizedWithCase: normalizer.NormalizeTokenKeepingCase(text),
+	}
+}
+
+// Matcher checks message tokens against one prohibited entry
+type Matcher interface {
+	// the prohibited entry as it is stored (without type prefix)
+	GetWord() string
+	GetPatternType() PatternType
+	// returns how many tokens starting from the position are matched, zero if there is no match
+	Match(tokens []Token, position int) int
+}
+
+type exactMatcher struct {
+	word string
+	// the word normalized the same way as tokens are
+	normalizedWord string
+}
+
+type stemmingMatcher struct {
+	word string
+	stem string
+}
+
+// matches a sequence of tokens, each of them with its own matcher
+type phraseMatcher struct {
+	word  string
+	parts []Matcher
+}
+
+type regexMatcher struct {
+	word        string
+	patternType PatternType
+	expression  *regexp.Regexp
+	// case-sensitive patterns are not checked against lowercase normalized tokens
+	isCaseSensitive bool
+}
+
+// matches tokens with the same case
+type caseSensitiveMatcher struct {
+	word string
+	// the word normalized the same way as tokens are, but with its case kept
+	normalizedWord string
+}
+
+// matches tokens that contain the word
+type substringMatcher struct {
+	word string
+	// with its case kept for case-sensitive matchers
+	normalizedWord  string
+	isCaseSensitive bool
+}
+
+// MatchOptions change how an entry is compared with tokens
+type MatchOptions struct {
+	CaseSensitive bool
+	// the entry can be any part of a token, not only the whole token
+	Substring bool
+}
+
+func (matcher *exactMatcher) GetWord() string {
+	return matcher.word
+}
+
+func (matcher *exactMatcher) GetPatternType() PatternType {
+	return ExactPattern
+}
+
+func (matcher *exactMatcher) Match(tokens []Token, position int) int {
+	return matchedTokenCount(tokens[position].Normalized == matcher.normalizedWord)
+}
+
+func (matcher *stemmingMatcher) GetWord() string {
+	return matcher.word
+}
+
+func (matcher *stemmingMatcher) GetPatternType() PatternType {
+	return ExactPattern
+}
+
+func (matcher *stemmingMatcher) Match(tokens []Token, position int) int {
+	return matchedTokenCount(stemming.Stem(tokens[position].Normalized) == matcher.stem)
+}
+
+func (matcher *phraseMatcher) GetWord() string {
+	return matcher.word
+}
+
+func (matcher *phraseMatcher) GetPatternType() PatternType {
+	return ExactPattern
+}
+
+func (matcher *phraseMatcher) Match(tokens []Token, position int) int {
+	if position+len(matcher.parts) > len(tokens) {
+		return 0
+	}
+
+	for i, part := range matcher.parts {
+		if part.Match(tokens, position+i) == 0 {
+			return 0
+		}
+	}
+
+	return len(matcher.parts)
+}
+
+func (matcher *regexMatcher) GetWord() string {
+	return matcher.word
+}
+
+func (matcher *regexMatcher) GetPatternType() PatternType {
+	return matcher.patternType
+}
+
+func (matcher *regexMatcher) Match(tokens []Token, position int) int {
+	token := tokens[position]
+	// patterns can't be normalized, so check both forms
+	normalized := token.Normalized
+	if matcher.isCaseSensitive {
+		normalized = token.NormalizedWithCase
+	}
+	return matchedTokenCount(matcher.expression.MatchString(token.Text) || matcher.expression.MatchString(normalized))
+}
+
+func (matcher *caseSensitiveMatcher) GetWord() string {
+	return matcher.word
+}
+
+func (matcher *caseSensitiveMatcher) GetPatternType() PatternType {
+	return ExactPattern
+}
+
+func (matcher *caseSensitiveMatcher) Match(tokens []Token, position int) int {
+	return matchedTokenCount(tokens[position].NormalizedWithCase == matcher.normalizedWord)
+}
+
+func (matcher *substringMatcher) GetWord() string {
+	return matcher.word
+}
+
+func (matcher *substringMatcher) GetPatternType() PatternType {
+	return ExactPattern
+}
+
+func (matcher *substringMatcher) Match(tokens []Token, position int) int {
+	if matcher.isCaseSensitive {
+		return matchedTokenCount(strings.Contains(tokens[position].NormalizedWithCase, matcher.normalizedWord))
+	}
+	return matchedTokenCount(strings.Contains(tokens[position].Normalized, matcher.normalizedWord))
+}
+
+func matchedTokenCount(isMatched bool) int {
+	if isMatched {
+		return 1
+	}
+	return 0
+}
+
+// ParsePattern splits user input like "re:f+u+c+k" into a pattern type and the pattern itself
+func ParsePattern(text string) (patternType PatternType, pattern string) {
+	lowerText := strings.ToLower(text)
+	if strings.HasPrefix(lowerText, regexPrefix) {
+		return RegexPattern, strings.TrimSpace(text[len(regexPrefix):])
+	} else if strings.HasPrefix(lowerText, globPrefix) {
+		return GlobPattern, strings.TrimSpace(text[len(globPrefix):])
+	} else {
+		return ExactPattern, text
+	}
+}
+
+// FormatPattern is the reverse of ParsePattern
+func FormatPattern(patternType PatternType, pattern string) string {
+	switch patternType {
+	case RegexPattern:
+		return regexPrefix + pattern
+	case GlobPattern:
+		return globPrefix + pattern
+	default:
+		return pattern
+	}
+}
+
+func globToRegex(glob string) string {
+	var builder strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			builder.WriteString(".*")
+		case '?':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return builder.String()
+}
+
+// MakeMatcher returns an error if the pattern can't be compiled
+func MakeMatcher(patternType PatternType, pattern string, normalizer Normalizer) (Matcher, error) {
+	return MakeMatcherWithOptions(patternType, pattern, normalizer, MatchOptions{})
+}
+
+func makeRegexMatcher(patternType PatternType, pattern string, expression string, options MatchOptions) (Matcher, error) {
+	// the expression should cover the whole token the same way exact words do
+	if !options.Substring {
+		expression = "^(?:" + expression + ")$"
+	}
+
+	if !options.CaseSensitive {
+		expression = "(?i)" + expression
+	}
+
+	compiledExpression, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	return &regexMatcher{
+		word:            pattern,
+		patternType:     patternType,
+		expression:      compiledExpression,
+		isCaseSensitive: options.CaseSensitive,
+	}, nil
+}
+
+// MakeMatcherWithOptions is the same as MakeMatcher but allows to match case-sensitively or by substrings
+func MakeMatcherWithOptions(patternType PatternType, pattern string, normalizer Normalizer, options MatchOptions) (Matcher, error) {
+	switch patternType {
+	case GlobPattern:
+		return makeRegexMatcher(patternType, pattern, globToRegex(pattern), options)
+	case RegexPattern:
+		return makeRegexMatcher(patternType, pattern, pattern, options)
+	default:
+		if options.Substring {
+			normalizedWord := normalizer.NormalizeToken(pattern)
+			if options.CaseSensitive {
+				normalizedWord = normalizer.NormalizeTokenKeepingCase(pattern)
+			}
+			return &substringMatcher{word: pattern, normalizedWord: normalizedWord, isCaseSensitive: options.CaseSensitive}, nil
+		} else if options.CaseSensitive {
+			return &caseSensitiveMatcher{word: pattern, normalizedWord: normalizer.NormalizeTokenKeepingCase(pattern)}, nil
+		}
+		return &exactMatcher{word: pattern, normalizedWord: normalizer.NormalizeToken(pattern)}, nil
+	}
+}
+
+// MakeStemmingMatcher makes a matcher that accepts any form of the word
+func MakeStemmingMatcher(word string, normalizer Normalizer) Matcher {
+	return &stemmingMatcher{word: word, stem: stemming.Stem(normalizer.NormalizeToken(word))}
+}
+
+// MakePhraseMatcher makes a matcher for a phrase from matchers of its words
+func MakePhraseMatcher(phrase string, parts []Matcher) Matcher {
+	return &phraseMatcher{word: phrase, parts: parts}
+}
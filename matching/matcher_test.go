@@ -0,0 +1,168 @@
+package matching
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func matchToken(matcher Matcher, token string) bool {
+	return matcher.Match([]Token{MakeToken(token, Normalizer{})}, 0) > 0
+}
+
+func TestParsePattern(t *testing.T) {
+	assert := require.New(t)
+
+	{
+		patternType, pattern := ParsePattern("word")
+		assert.Equal(ExactPattern, patternType)
+		assert.Equal("word", pattern)
+	}
+
+	{
+		patternType, pattern := ParsePattern("glob:бля*")
+		assert.Equal(GlobPattern, patternType)
+		assert.Equal("бля*", pattern)
+	}
+
+	{
+		patternType, pattern := ParsePattern("RE: f+u+c+k")
+		assert.Equal(RegexPattern, patternType)
+		assert.Equal("f+u+c+k", pattern)
+		assert.Equal("re:f+u+c+k", FormatPattern(patternType, pattern))
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	assert := require.New(t)
+
+	{
+		matcher, err := MakeMatcher(ExactPattern, "Слово", Normalizer{})
+		assert.NoError(err)
+		assert.True(matchToken(matcher, "слово"))
+		assert.False(matchToken(matcher, "слова"))
+	}
+
+	{
+		matcher, err := MakeMatcher(GlobPattern, "бля*", Normalizer{})
+		assert.NoError(err)
+		assert.True(matchToken(matcher, "бля"))
+		assert.True(matchToken(matcher, "Блять"))
+		assert.False(matchToken(matcher, "рубля"))
+	}
+
+	{
+		matcher, err := MakeMatcher(GlobPattern, "a.b?", Normalizer{})
+		assert.NoError(err)
+		assert.True(matchToken(matcher, "a.bc"))
+		assert.False(matchToken(matcher, "axbc"))
+	}
+
+	{
+		matcher, err := MakeMatcher(RegexPattern, "f+u+c+k", Normalizer{})
+		assert.NoError(err)
+		assert.True(matchToken(matcher, "FUUUCK"))
+		assert.False(matchToken(matcher, "fucking"))
+	}
+
+	{
+		matcher := MakeStemmingMatcher("работа", Normalizer{})
+		assert.True(matchToken(matcher, "Работой"))
+		assert.True(matchToken(matcher, "работе"))
+		assert.False(matchToken(matcher, "рабочий"))
+	}
+
+	{
+		_, err := MakeMatcher(RegexPattern, "f(u", Normalizer{})
+		assert.Error(err)
+	}
+}
+
+func TestPhraseMatcher(t *testing.T) {
+	assert := require.New(t)
+
+	first, err := MakeMatcher(ExactPattern, "как", Normalizer{})
+	assert.NoError(err)
+	second, err := MakeMatcher(ExactPattern, "бы", Normalizer{})
+	assert.NoError(err)
+
+	matcher := MakePhraseMatcher("как бы", []Matcher{first, second})
+	assert.Equal("как бы", matcher.GetWord())
+
+	tokens := []Token{}
+	for _, text := range []string{"Как", "бы", "как", "же", "как"} {
+		tokens = append(tokens, MakeToken(text, Normalizer{}))
+	}
+
+	assert.Equal(2, matcher.Match(tokens, 0))
+	assert.Equal(0, matcher.Match(tokens, 1))
+	assert.Equal(0, matcher.Match(tokens, 2))
+	// the phrase doesn't fit into the rest of the text
+	assert.Equal(0, matcher.Match(tokens, 4))
+}
+
+func TestMatchOptions(t *testing.T) {
+	assert := require.New(t)
+
+	{
+		matcher, err := MakeMatcherWithOptions(ExactPattern, "IT", Normalizer{}, MatchOptions{CaseSensitive: true})
+		assert.NoError(err)
+		assert.True(matchToken(matcher, "IT"))
+		assert.False(matchToken(matcher, "it"))
+		assert.False(matchToken(matcher, "It"))
+	}
+
+	{
+		matcher, err := MakeMatcherWithOptions(ExactPattern, "бля", Normalizer{}, MatchOptions{Substring: true})
+		assert.NoError(err)
+		assert.True(matchToken(matcher, "Бля"))
+		assert.True(matchToken(matcher, "рубля"))
+		assert.False(matchToken(matcher, "бл"))
+	}
+
+	{
+		matcher, err := MakeMatcherWithOptions(ExactPattern, "IT", Normalizer{}, MatchOptions{CaseSensitive: true, Substring: true})
+		assert.NoError(err)
+		assert.True(matchToken(matcher, "ITшник"))
+		assert.False(matchToken(matcher, "itшник"))
+	}
+
+	{
+		matcher, err := MakeMatcherWithOptions(GlobPattern, "бл?", Normalizer{}, MatchOptions{Substring: true})
+		assert.NoError(err)
+		assert.True(matchToken(matcher, "рубля"))
+		assert.False(matchToken(matcher, "рубл"))
+	}
+
+	{
+		matcher, err := MakeMatcherWithOptions(RegexPattern, "I[TS]", Normalizer{}, MatchOptions{CaseSensitive: true})
+		assert.NoError(err)
+		assert.True(matchToken(matcher, "IS"))
+		assert.False(matchToken(matcher, "is"))
+		assert.False(matchToken(matcher, "ITS"))
+	}
+
+	// lookalike letters are folded for case-sensitive entries too
+	{
+		normalizer := Normalizer{FoldConfusables: true}
+		checkToken := func(matcher Matcher, token string) bool {
+			return matcher.Match([]Token{MakeToken(token, normalizer)}, 0) > 0
+		}
+
+		for _, options := range []MatchOptions{{CaseSensitive: true}, {CaseSensitive: true, Substring: true}} {
+			matcher, err := MakeMatcherWithOptions(ExactPattern, "IT", normalizer, options)
+			assert.NoError(err)
+			assert.True(checkToken(matcher, "I\u0422"))
+			assert.False(checkToken(matcher, "i\u0442"))
+		}
+
+		matcher, err := MakeMatcherWithOptions(RegexPattern, "I[TS]", normalizer, MatchOptions{CaseSensitive: true})
+		assert.NoError(err)
+		assert.True(checkToken(matcher, "I\u0422"))
+		assert.False(checkToken(matcher, "i\u0442"))
+
+		matcher, err = MakeMatcherWithOptions(ExactPattern, "IT", normalizer, MatchOptions{CaseSensitive: true})
+		assert.NoError(err)
+		scanner := MakeScanner([]Matcher{matcher})
+		assert.Equal([]WordMatch{{Word: "IT", Surface: "I\u0422"}}, scanner.FindMatches([]Token{MakeToken("I\u0422", normalizer), MakeToken("it", normalizer)}))
+	}
+}
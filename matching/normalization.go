@@ -0,0 +1,219 @@
+package matching
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalizer folds the tricks that are used to evade the filter.
+// Every step can be switched separately, the zero value only lowercases tokens
+type Normalizer struct {
+	// Latin letters inside Cyrillic words and vice versa
+	FoldConfusables bool
+	// zero-width spaces, soft hyphens, combining marks, etc.
+	StripInvisible bool
+	// "бляяяя" -> "бля"
+	CollapseRepeats bool
+	// "h3ll0" -> "hello"
+	MapLeet bool
+}
+
+var latinToCyrillicConfusables = map[rune]rune{
+	'a': 'а',
+	'b': 'в',
+	'c': 'с',
+	'e': 'е',
+	'h': 'н',
+	'k': 'к',
+	'm': 'м',
+	'o': 'о',
+	'p': 'р',
+	't': 'т',
+	'x': 'х',
+	'y': 'у',
+}
+
+var cyrillicToLatinConfusables = map[rune]rune{
+	'а': 'a',
+	'в': 'b',
+	'с': 'c',
+	'е': 'e',
+	'н': 'h',
+	'к': 'k',
+	'м': 'm',
+	'о': 'o',
+	'р': 'p',
+	'т': 't',
+	'х': 'x',
+	'у': 'y',
+}
+
+var latinLeet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+}
+
+var cyrillicLeet = map[rune]rune{
+	'0': 'о',
+	'3': 'з',
+	'4': 'ч',
+	'6': 'б',
+	'8': 'в',
+}
+
+// leet symbols that are parts of punctuation and have to be processed before it's removed
+var latinLeetSymbols = map[rune]rune{
+	'@': 'a',
+	'$': 's',
+}
+
+var cyrillicLeetSymbols = map[rune]rune{
+	'@': 'а',
+}
+
+// combining marks that are parts of Cyrillic letters and shouldn't be lost
+var cyrillicCompositions = map[string]string{
+	"и\u0306": "й",
+	"И\u0306": "Й",
+	"е\u0308": "ё",
+	"Е\u0308": "Ё",
+}
+
+func isInvisible(r rune) bool {
+	return unicode.Is(unicode.Cf, r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r)
+}
+
+// NormalizeText processes the whole text before it's split into tokens
+func (normalizer Normalizer) NormalizeText(text string) string {
+	if normalizer.StripInvisible {
+		for decomposed, composed := range cyrillicCompositions {
+			text = strings.Replace(text, decomposed, composed, -1)
+		}
+		text = strings.Map(func(r rune) rune {
+			if isInvisible(r) {
+				return -1
+			}
+			return r
+		}, text)
+	}
+
+	if normalizer.MapLeet {
+		// keep leet symbols that would be removed with the punctuation
+		// but only when they are glued to letters ("$hit", "b@d"), not "@username"
+		runes := []rune(text)
+		for i, r := range runes {
+			if r != '$' && r != '@' {
+				continue
+			}
+
+			isLetterBefore := i > 0 && unicode.IsLetter(runes[i-1])
+			isLetterAfter := i+1 < len(runes) && unicode.IsLetter(runes[i+1])
+			if (r == '$' && isLetterAfter) || (r == '@' && isLetterBefore && isLetterAfter) {
+				runes[i] = latinLeetSymbols[r]
+				if cyrillicSymbol, ok := cyrillicLeetSymbols[r]; ok && unicode.Is(unicode.Cyrillic, runes[i-1]) {
+					runes[i] = cyrillicSymbol
+				}
+			}
+		}
+		text = string(runes)
+	}
+
+	return text
+}
+
+// decides which script the token is written in, letters that have lookalikes
+// in the other script are only taken into account when there are no other letters
+func isMostlyCyrillic(token string) (isCyrillic bool, hasLetters bool) {
+	cyrillicCount := 0
+	latinCount := 0
+	distinctCyrillicCount := 0
+	distinctLatinCount := 0
+	for _, r := range token {
+		if unicode.Is(unicode.Cyrillic, r) {
+			cyrillicCount++
+			if _, ok := cyrillicToLatinConfusables[r]; !ok {
+				distinctCyrillicCount++
+			}
+		} else if unicode.Is(unicode.Latin, r) {
+			latinCount++
+			if _, ok := latinToCyrillicConfusables[r]; !ok {
+				distinctLatinCount++
+			}
+		}
+	}
+
+	if distinctCyrillicCount != distinctLatinCount {
+		return distinctCyrillicCount > distinctLatinCount, true
+	}
+	return cyrillicCount > latinCount, cyrillicCount+latinCount > 0
+}
+
+// the mappings are lowercase, uppercase letters are mapped to uppercase ones
+func mapRunes(token string, mapping map[rune]rune) string {
+	return strings.Map(func(r rune) rune {
+		if mapped, ok := mapping[unicode.ToLower(r)]; ok {
+			if unicode.IsUpper(r) {
+				return unicode.ToUpper(mapped)
+			}
+			return mapped
+		}
+		return r
+	}, token)
+}
+
+func collapseRepeats(token string) string {
+	var builder strings.Builder
+	var previous rune = -1
+	for _, r := range token {
+		if r != previous || !unicode.IsLetter(r) {
+			builder.WriteRune(r)
+		}
+		previous = r
+	}
+	return builder.String()
+}
+
+// NormalizeToken returns a lowercase form of a single token with the enabled steps applied
+func (normalizer Normalizer) NormalizeToken(token string) string {
+	return normalizer.normalizeToken(strings.ToLower(token))
+}
+
+// NormalizeTokenKeepingCase is the same as NormalizeToken but doesn't lowercase the token
+func (normalizer Normalizer) NormalizeTokenKeepingCase(token string) string {
+	return normalizer.normalizeToken(token)
+}
+
+func (normalizer Normalizer) normalizeToken(token string) string {
+	// "ё" is commonly written as "е"
+	token = strings.NewReplacer("ё", "е", "Ё", "Е").Replace(token)
+
+	isCyrillic, hasLetters := isMostlyCyrillic(strings.ToLower(token))
+
+	// numbers without letters are left as they are
+	if normalizer.MapLeet && hasLetters {
+		if isCyrillic {
+			token = mapRunes(token, cyrillicLeet)
+		} else {
+			token = mapRunes(token, latinLeet)
+		}
+	}
+
+	if normalizer.FoldConfusables {
+		if isCyrillic {
+			token = mapRunes(token, latinToCyrillicConfusables)
+		} else {
+			token = mapRunes(token, cyrillicToLatinConfusables)
+		}
+	}
+
+	if normalizer.CollapseRepeats {
+		token = collapseRepeats(token)
+	}
+
+	return token
+}
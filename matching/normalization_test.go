@@ -0,0 +1,86 @@
+package matching
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func normalize(normalizer Normalizer, text string) string {
+	return normalizer.NormalizeToken(normalizer.NormalizeText(text))
+}
+
+func TestNormalizationEvasionsCorpus(t *testing.T) {
+	assert := require.New(t)
+
+	normalizer := Normalizer{
+		FoldConfusables: true,
+		StripInvisible:  true,
+		CollapseRepeats: true,
+		MapLeet:         true,
+	}
+
+	testCases := []struct {
+		evasion  string
+		expected string
+	}{
+		// Latin letters inside Cyrillic words
+		{"пpивeт", "привет"},
+		{"xyй", "хуй"},
+		{"ПPИBET", "привет"},
+		{"cука", "сука"},
+		// Cyrillic letters inside Latin words
+		{"hеllо", "helo"},
+		{"fuсk", "fuck"},
+		// invisible and combining characters
+		{"при\u200bвет", "привет"},
+		{"при\u00adвет", "привет"},
+		{"п\u0301ривет", "привет"},
+		{"\ufeffпривет\u2060", "привет"},
+		// decomposed letters are not broken, "ё" is the same as "е"
+		{"мои\u0306", "мой"},
+		{"е\u0308лка", "елка"},
+		// repeated letters
+		{"бляяяяя", "бля"},
+		{"нееееет", "нет"},
+		// leet
+		{"h3ll0", "helo"},
+		{"$hit", "shit"},
+		{"b@d", "bad"},
+		{"пр0сто", "просто"},
+		{"3дравствуйте", "здравствуйте"},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(testCase.expected, normalize(normalizer, testCase.evasion), testCase.evasion)
+	}
+}
+
+func TestNormalizationSteps(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal("пpивeт", normalize(Normalizer{}, "Пpивeт"))
+	assert.Equal("привет", normalize(Normalizer{FoldConfusables: true}, "Пpивeт"))
+	assert.Equal("при\u200bвет", normalize(Normalizer{}, "при\u200bвет"))
+	assert.Equal("привет", normalize(Normalizer{StripInvisible: true}, "при\u200bвет"))
+	assert.Equal("бляяя", normalize(Normalizer{}, "бляяя"))
+	assert.Equal("бля", normalize(Normalizer{CollapseRepeats: true}, "бляяя"))
+	assert.Equal("h3ll0", normalize(Normalizer{}, "h3ll0"))
+	assert.Equal("hello", normalize(Normalizer{MapLeet: true}, "h3ll0"))
+
+	// numbers and mentions are kept as they are
+	assert.Equal("2020", normalize(Normalizer{MapLeet: true}, "2020"))
+	assert.Equal("@user", normalize(Normalizer{MapLeet: true}, "@user"))
+}
+
+func TestNormalizationKeepingCase(t *testing.T) {
+	assert := require.New(t)
+
+	normalizer := Normalizer{FoldConfusables: true, StripInvisible: true}
+
+	// "Т" is Cyrillic
+	assert.Equal("IT", normalizer.NormalizeTokenKeepingCase("I\u0422"))
+	assert.Equal("iT", normalizer.NormalizeTokenKeepingCase("i\u0422"))
+	assert.Equal("ПРИВЕТ", normalizer.NormalizeTokenKeepingCase("ПPИBET"))
+	assert.Equal("Елка", normalizer.NormalizeTokenKeepingCase("Ёлка"))
+	assert.Equal("IT", normalizer.NormalizeTokenKeepingCase(normalizer.NormalizeText("I\u200bT")))
+}
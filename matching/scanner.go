@@ -0,0 +1,210 @@
+package matching
+
+import (
+	"github.com/gameraccoon/telegram-prohibited-words-bot/stemming"
+	"sort"
+	"strings"
+)
+
+// symbols of tokens used by matchers that can be compiled into an automaton
+type symbolChannel int
+
+const (
+	normalizedChannel symbolChannel = iota
+	stemChannel
+	// normalized tokens with their case kept
+	caseChannel
+)
+
+// a matcher that can be represented as a sequence of token symbols
+type sequenceMatcher interface {
+	Matcher
+	getSequence() (channel symbolChannel, symbols []string, ok bool)
+}
+
+type channelAutomaton struct {
+	automaton *automaton
+	// matchers in order of sequences added to the automaton
+	matchers []Matcher
+}
+
+// Scanner is a compiled set of matchers of a chat.
+// Exact words, stems and phrases are found by automatons in a single pass,
+// other patterns are checked one by one
+type Scanner struct {
+	automatons map[symbolChannel]*channelAutomaton
+	matchers   []Matcher
+}
+
+type scannerMatch struct {
+	matcher Matcher
+	start   int
+	length  int
+}
+
+func (matcher *exactMatcher) getSequence() (symbolChannel, []string, bool) {
+	return normalizedChannel, []string{matcher.normalizedWord}, true
+}
+
+func (matcher *stemmingMatcher) getSequence() (symbolChannel, []string, bool) {
+	return stemChannel, []string{matcher.stem}, true
+}
+
+func (matcher *caseSensitiveMatcher) getSequence() (symbolChannel, []string, bool) {
+	return caseChannel, []string{matcher.normalizedWord}, true
+}
+
+func (matcher *phraseMatcher) getSequence() (channel symbolChannel, symbols []string, ok bool) {
+	for i, part := range matcher.parts {
+		partSequence, isSequence := part.(sequenceMatcher)
+		if !isSequence {
+			return
+		}
+
+		partChannel, partSymbols, isPartOk := partSequence.getSequence()
+		if !isPartOk || (i > 0 && partChannel != channel) {
+			return
+		}
+
+		channel = partChannel
+		symbols = append(symbols, partSymbols...)
+	}
+
+	return channel, symbols, len(symbols) > 0
+}
+
+func MakeScanner(matchers []Matcher) *Scanner {
+	scanner := &Scanner{
+		automatons: map[symbolChannel]*channelAutomaton{},
+	}
+
+	for _, matcher := range matchers {
+		if sequence, isSequence := matcher.(sequenceMatcher); isSequence {
+			if channel, symbols, ok := sequence.getSequence(); ok {
+				channelData, ok := scanner.automatons[channel]
+				if !ok {
+					channelData = &channelAutomaton{automaton: makeAutomaton()}
+					scanner.automatons[channel] = channelData
+				}
+
+				channelData.automaton.addSequence(symbols)
+				channelData.matchers = append(channelData.matchers, matcher)
+				continue
+			}
+		}
+
+		scanner.matchers = append(scanner.matchers, matcher)
+	}
+
+	for _, channelData := range scanner.automatons {
+		channelData.automaton.build()
+	}
+
+	return scanner
+}
+
+func getTokenSymbols(tokens []Token, channel symbolChannel) (symbols []string) {
+	for _, token := range tokens {
+		switch channel {
+		case stemChannel:
+			symbols = append(symbols, stemming.Stem(token.Normalized))
+		case caseChannel:
+			symbols = append(symbols, token.NormalizedWithCase)
+		default:
+			symbols = append(symbols, token.Normalized)
+		}
+	}
+	return
+}
+
+// FindMatches returns all matches in order of their appearance in the tokens,
+// tokens of a matched phrase are not matched by other entries
+func (scanner *Scanner) FindMatches(tokens []Token) (foundWords []WordMatch) {
+	matches := []scannerMatch{}
+
+	for channel, channelData := range scanner.automatons {
+		for _, match := range channelData.automaton.findAll(getTokenSymbols(tokens, channel)) {
+			matches = append(matches, scannerMatch{
+				matcher: channelData.matchers[match.sequenceIdx],
+				start:   match.start,
+				length:  match.length,
+			})
+		}
+	}
+
+	for _, matcher := range scanner.matchers {
+		for position := range tokens {
+			if length := matcher.Match(tokens, position); length > 0 {
+				matches = append(matches, scannerMatch{
+					matcher: matcher,
+					start:   position,
+					length:  length,
+				})
+			}
+		}
+	}
+
+	// results of different automatons and matchers are mixed, make the order stable
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		if matches[i].length != matches[j].length {
+			return matches[i].length > matches[j].length
+		}
+		return matches[i].matcher.GetWord() < matches[j].matcher.GetWord()
+	})
+
+	// a word that is matched exactly or by its stem is not matched fuzzily at the same place
+	type wordPosition struct {
+		word  string
+		start int
+	}
+	exactMatches := map[wordPosition]bool{}
+	for _, match := range matches {
+		if _, isFuzzy := match.matcher.(*fuzzyMatcher); !isFuzzy {
+			exactMatches[wordPosition{word: match.matcher.GetWord(), start: match.start}] = true
+		}
+	}
+
+	// the last accepted match, matches are sorted so it ends further than the others
+	coveredStart, coveredEnd := 0, 0
+
+	for _, match := range matches {
+		distance := 0
+		if fuzzy, isFuzzy := match.matcher.(*fuzzyMatcher); isFuzzy {
+			if exactMatches[wordPosition{word: fuzzy.word, start: match.start}] {
+				continue
+			}
+			distance = fuzzy.getDistance(tokens[match.start])
+		}
+
+		// the longest match wins, only matches of the same tokens are kept together
+		matchEnd := match.start + match.length
+		if match.start < coveredEnd && (match.start != coveredStart || matchEnd != coveredEnd) {
+			continue
+		}
+		coveredStart, coveredEnd = match.start, matchEnd
+
+		var surface strings.Builder
+		for i, token := range tokens[match.start : match.start+match.length] {
+			if i > 0 {
+				// keep hyphens of compounds but not punctuation around words
+				if len(token.Separator) == 0 || strings.ContainsAny(token.Separator, " \t\n") {
+					surface.WriteString(" ")
+				} else {
+					surface.WriteString(token.Separator)
+				}
+			}
+			surface.WriteString(token.Text)
+		}
+
+		foundWords = append(foundWords, WordMatch{
+			Word:     match.matcher.GetWord(),
+			Surface:  surface.String(),
+			Distance: distance,
+		})
+	}
+
+	return
+}
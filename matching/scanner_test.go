@@ -0,0 +1,81 @@
+package matching
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func makeTestTokens(text string) (tokens []Token) {
+	for _, word := range strings.Fields(text) {
+		tokens = append(tokens, MakeToken(word, Normalizer{}))
+	}
+	return
+}
+
+func TestAutomaton(t *testing.T) {
+	assert := require.New(t)
+
+	automaton := makeAutomaton()
+	he := automaton.addSequence([]string{"he"})
+	she := automaton.addSequence([]string{"she"})
+	heSaid := automaton.addSequence([]string{"he", "said"})
+	sheSaidHe := automaton.addSequence([]string{"she", "said", "he"})
+	saidHe := automaton.addSequence([]string{"said", "he"})
+	automaton.build()
+
+	matches := automaton.findAll(strings.Fields("she said he said she"))
+
+	assert.ElementsMatch([]automatonMatch{
+		{sequenceIdx: she, start: 0, length: 1},
+		{sequenceIdx: sheSaidHe, start: 0, length: 3},
+		{sequenceIdx: saidHe, start: 1, length: 2},
+		{sequenceIdx: he, start: 2, length: 1},
+		{sequenceIdx: heSaid, start: 2, length: 2},
+		{sequenceIdx: she, start: 4, length: 1},
+	}, matches)
+}
+
+func TestScanner(t *testing.T) {
+	assert := require.New(t)
+
+	exact, _ := MakeMatcher(ExactPattern, "бы", Normalizer{})
+	glob, _ := MakeMatcher(GlobPattern, "бля*", Normalizer{})
+	stem := MakeStemmingMatcher("работа", Normalizer{})
+	phrase := MakePhraseMatcher("как бы", []Matcher{
+		&exactMatcher{word: "как", normalizedWord: "как"},
+		&exactMatcher{word: "бы", normalizedWord: "бы"},
+	})
+	// phrases with patterns can't be compiled into automatons
+	mixedPhrase := MakePhraseMatcher("бля* буду", []Matcher{
+		glob,
+		&exactMatcher{word: "буду", normalizedWord: "буду"},
+	})
+
+	scanner := MakeScanner([]Matcher{exact, glob, stem, phrase, mixedPhrase})
+	assert.Equal(2, len(scanner.automatons))
+	assert.Equal(2, len(scanner.matchers))
+
+	// words of the phrases are not matched again
+	assert.Equal([]WordMatch{
+		{Word: "как бы", Surface: "Как бы"},
+		{Word: "работа", Surface: "работой"},
+		{Word: "бля* буду", Surface: "блять буду"},
+		{Word: "бы", Surface: "бы"},
+	}, scanner.FindMatches(makeTestTokens("Как бы работой блять буду бы")))
+
+	assert.Equal(0, len(scanner.FindMatches(nil)))
+	assert.Equal(0, len(MakeScanner(nil).FindMatches(makeTestTokens("как бы"))))
+
+	// different entries of the same tokens are all matched, overlapping phrases are not
+	anyB, _ := MakeMatcher(GlobPattern, "б*", Normalizer{})
+	otherPhrase := MakePhraseMatcher("бы так", []Matcher{
+		&exactMatcher{word: "бы", normalizedWord: "бы"},
+		&exactMatcher{word: "так", normalizedWord: "так"},
+	})
+	assert.Equal([]WordMatch{
+		{Word: "как бы", Surface: "как бы"},
+		{Word: "б*", Surface: "бы"},
+		{Word: "бы", Surface: "бы"},
+	}, MakeScanner([]Matcher{exact, anyB, phrase, otherPhrase}).FindMatches(makeTestTokens("как бы так, бы")))
+}
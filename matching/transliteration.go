@@ -0,0 +1,173 @@
+package matching
+
+import (
+	"strings"
+	"unicode"
+)
+
+// a way to write Cyrillic letters with Latin ones
+type transliterationScheme map[rune]string
+
+var baseScheme = transliterationScheme{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'з': "z", 'и': "i",
+	'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s",
+	'т': "t", 'у': "u", 'ф': "f", 'ч': "ch", 'ш': "sh", 'ь': "", 'ъ': "", 'э': "e",
+}
+
+// letters that differ between schemes, apostrophes of the schemes are omitted
+// because they are removed from messages before matching
+var transliterationSchemes = []transliterationScheme{
+	// GOST 7.79-2000 system B
+	{'ё': "yo", 'ж': "zh", 'й': "j", 'х': "x", 'ц': "cz", 'щ': "shh", 'ы': "y", 'ю': "yu", 'я': "ya"},
+	// ICAO, used in passports
+	{'ё': "e", 'ж': "zh", 'й': "i", 'х': "kh", 'ц': "ts", 'щ': "shch", 'ы': "y", 'ъ': "ie", 'ю': "iu", 'я': "ia"},
+	// informal
+	{'ё': "yo", 'ж': "zh", 'й': "y", 'х': "h", 'ц': "c", 'щ': "sch", 'ы': "y", 'ю': "yu", 'я': "ya"},
+	// informal with "j" for iotated vowels
+	{'ё': "jo", 'ж': "j", 'й': "j", 'х': "h", 'ц': "c", 'щ': "sh", 'ы': "i", 'ю': "ju", 'я': "ja"},
+}
+
+// Latin letter combinations that are read as one Cyrillic letter, longest first
+var latinCombinations = []struct {
+	latin    string
+	cyrillic string
+}{
+	{"shch", "щ"}, {"sch", "щ"}, {"shh", "щ"},
+	{"zh", "ж"}, {"kh", "х"}, {"ts", "ц"}, {"cz", "ц"}, {"ch", "ч"}, {"sh", "ш"},
+	{"yu", "ю"}, {"ju", "ю"}, {"iu", "ю"}, {"ya", "я"}, {"ja", "я"}, {"ia", "я"}, {"yo", "ё"}, {"jo", "ё"},
+	{"a", "а"}, {"b", "б"}, {"c", "ц"}, {"d", "д"}, {"e", "е"}, {"f", "ф"}, {"g", "г"}, {"h", "х"},
+	{"i", "и"}, {"j", "й"}, {"k", "к"}, {"l", "л"}, {"m", "м"}, {"n", "н"}, {"o", "о"}, {"p", "п"},
+	{"q", "к"}, {"r", "р"}, {"s", "с"}, {"t", "т"}, {"u", "у"}, {"v", "в"}, {"w", "в"}, {"x", "х"},
+	{"z", "з"},
+}
+
+func isLatinVowel(r rune) bool {
+	return strings.ContainsRune("aeiouy", r)
+}
+
+func getLettersScript(text string) (isCyrillic bool, isLatin bool) {
+	isCyrillic, isLatin = true, true
+	hasLetters := false
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		hasLetters = true
+		if !unicode.Is(unicode.Cyrillic, r) {
+			isCyrillic = false
+		}
+		if !unicode.Is(unicode.Latin, r) {
+			isLatin = false
+		}
+	}
+	return isCyrillic && hasLetters, isLatin && hasLetters
+}
+
+func transliterateToLatin(text string, scheme transliterationScheme) string {
+	var builder strings.Builder
+	for _, r := range text {
+		if latin, ok := scheme[r]; ok {
+			builder.WriteString(latin)
+		} else if latin, ok := baseScheme[r]; ok {
+			builder.WriteString(latin)
+		} else {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+func transliterateToCyrillic(text string) string {
+	var builder strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		// "y" not followed by "a", "o" or "u" is "ы" after consonants and "й" after vowels
+		if runes[i] == 'y' && (i+1 >= len(runes) || !strings.ContainsRune("aou", runes[i+1])) {
+			if i > 0 && isLatinVowel(runes[i-1]) {
+				builder.WriteString("й")
+			} else {
+				builder.WriteString("ы")
+			}
+			i++
+			continue
+		}
+
+		isReplaced := false
+		for _, combination := range latinCombinations {
+			if strings.HasPrefix(string(runes[i:]), combination.latin) {
+				builder.WriteString(combination.cyrillic)
+				i += len([]rune(combination.latin))
+				isReplaced = true
+				break
+			}
+		}
+
+		if !isReplaced {
+			builder.WriteRune(runes[i])
+			i++
+		}
+	}
+	return builder.String()
+}
+
+// Transliterate returns the ways the word can be written in the other script,
+// Cyrillic words get several Latin variants and Latin words get one Cyrillic reading
+func Transliterate(word string) (variants []string) {
+	lowerWord := strings.ToLower(word)
+	isCyrillic, isLatin := getLettersScript(lowerWord)
+
+	if isLatin {
+		return []string{transliterateToCyrillic(lowerWord)}
+	}
+
+	if !isCyrillic {
+		return
+	}
+
+	for _, scheme := range transliterationSchemes {
+		variant := transliterateToLatin(lowerWord, scheme)
+
+		isDuplicate := false
+		for _, existingVariant := range variants {
+			if existingVariant == variant {
+				isDuplicate = true
+				break
+			}
+		}
+
+		if !isDuplicate {
+			variants = append(variants, variant)
+		}
+	}
+	return
+}
+
+// MakeTransliteratedMatchers makes exact matchers for all transliterations of a word or a phrase,
+// the matches are credited to the original word
+func MakeTransliteratedMatchers(word string, normalizer Normalizer) (matchers []Matcher) {
+	// different variants can become the same after normalization ("shh" and "sh" with collapsed repeats)
+	addedVariants := map[string]bool{}
+
+	for _, variant := range Transliterate(word) {
+		parts := []Matcher{}
+		normalizedParts := []string{}
+		for _, part := range strings.FieldsFunc(variant, func(r rune) bool { return !IsTokenRune(r) }) {
+			normalizedPart := normalizer.NormalizeToken(part)
+			parts = append(parts, &exactMatcher{word: word, normalizedWord: normalizedPart})
+			normalizedParts = append(normalizedParts, normalizedPart)
+		}
+
+		normalizedVariant := strings.Join(normalizedParts, " ")
+		if addedVariants[normalizedVariant] {
+			continue
+		}
+		addedVariants[normalizedVariant] = true
+
+		if len(parts) == 1 {
+			matchers = append(matchers, parts[0])
+		} else if len(parts) > 1 {
+			matchers = append(matchers, MakePhraseMatcher(word, parts))
+		}
+	}
+	return
+}
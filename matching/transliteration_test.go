@@ -0,0 +1,77 @@
+package matching
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTransliterate(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal([]string{"privet"}, Transliterate("Привет"))
+	assert.Equal([]string{"blin"}, Transliterate("блин"))
+	assert.Equal([]string{"xorosho", "khorosho", "horosho"}, Transliterate("хорошо"))
+	assert.Equal([]string{"shhuka", "shchuka", "schuka", "shuka"}, Transliterate("щука"))
+	assert.Equal([]string{"zhizn", "jizn"}, Transliterate("жизнь"))
+	assert.Equal([]string{"yolka", "elka", "jolka"}, Transliterate("ёлка"))
+	assert.Equal([]string{"kak by", "kak bi"}, Transliterate("как бы"))
+
+	assert.Equal([]string{"привет"}, Transliterate("privet"))
+	assert.Equal([]string{"блин"}, Transliterate("BLIN"))
+	assert.Equal([]string{"хорошо"}, Transliterate("khorosho"))
+	assert.Equal([]string{"щука"}, Transliterate("schuka"))
+	assert.Equal([]string{"мой"}, Transliterate("moy"))
+	assert.Equal([]string{"мы"}, Transliterate("my"))
+	assert.Equal([]string{"юла"}, Transliterate("yula"))
+
+	assert.Equal(0, len(Transliterate("123")))
+	assert.Equal(0, len(Transliterate("mixedсмесь")))
+}
+
+func TestTransliteratedMatchers(t *testing.T) {
+	assert := require.New(t)
+
+	normalizer := Normalizer{FoldConfusables: true}
+
+	scanner := MakeScanner(append(
+		MakeTransliteratedMatchers("хорошо", normalizer),
+		MakeTransliteratedMatchers("blin", normalizer)...,
+	))
+
+	tokens := []Token{}
+	for _, word := range []string{"Khorosho", "horosho", "хорошо", "Блин", "blin"} {
+		tokens = append(tokens, MakeToken(word, normalizer))
+	}
+
+	// the original words are not matched by these matchers
+	assert.Equal([]WordMatch{
+		{Word: "хорошо", Surface: "Khorosho"},
+		{Word: "хорошо", Surface: "horosho"},
+		{Word: "blin", Surface: "Блин"},
+	}, scanner.FindMatches(tokens))
+}
+
+func TestTransliteratedMatchersWithCollapsedRepeats(t *testing.T) {
+	assert := require.New(t)
+
+	normalizer := Normalizer{FoldConfusables: true, CollapseRepeats: true}
+
+	// "shh" and "sh" are the same with collapsed repeats
+	assert.Equal(3, len(MakeTransliteratedMatchers("щи", normalizer)))
+
+	scanner := MakeScanner(append(
+		MakeTransliteratedMatchers("щи", normalizer),
+		MakeTransliteratedMatchers("чаща", normalizer)...,
+	))
+
+	tokens := []Token{}
+	for _, word := range []string{"shi", "shhi", "chasha"} {
+		tokens = append(tokens, MakeToken(word, normalizer))
+	}
+
+	assert.Equal([]WordMatch{
+		{Word: "щи", Surface: "shi"},
+		{Word: "щи", Surface: "shhi"},
+		{Word: "чаща", Surface: "chasha"},
+	}, scanner.FindMatches(tokens))
+}
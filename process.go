@@ -3,10 +3,20 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"github.com/gameraccoon/telegram-prohibited-words-bot/database"
+	"github.com/gameraccoon/telegram-prohibited-words-bot/matching"
 	"github.com/gameraccoon/telegram-prohibited-words-bot/processing"
+	"github.com/gameraccoon/telegram-prohibited-words-bot/schedule"
+	"github.com/gameraccoon/telegram-prohibited-words-bot/telegramChat"
 	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/nicksnyder/go-i18n/i18n"
+	"log"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
 )
 
 type ProcessorFunc func(*processing.ProcessData)
@@ -27,221 +37,2256 @@ func isSenderAnAdmin(data *processing.ProcessData) bool {
 	}
 }
 
+// parses one of comma-separated words of a command, phrases are stored with single spaces
+func parseWordParameter(word string) (patternType matching.PatternType, pattern string) {
+	patternType, pattern = matching.ParsePattern(strings.Trim(word, " \t\n"))
+	if patternType == matching.ExactPattern {
+		pattern = strings.Join(strings.Fields(pattern), " ")
+	}
+	return
+}
+
+// "/score all" shows users that left the chat, so a category can't have this name
+const reservedCategoryName = "all"
+
+// splits "category: word1, word2" into the category name and the list of words,
+// not ok if the category name is reserved
+func parseCategoryPrefix(message string) (category string, words string, ok bool) {
+	separatorIdx := strings.Index(message, ":")
+	if separatorIdx == -1 {
+		return "", message, true
+	}
+
+	// "re:" and "glob:" are pattern prefixes, not categories
+	if patternType, _ := matching.ParsePattern(message); patternType != matching.ExactPattern {
+		return "", message, true
+	}
+
+	prefix := strings.ToLower(strings.TrimSpace(message[:separatorIdx]))
+	// category names are single words that start with a letter, "12:00" is not a category
+	if len(prefix) == 0 || strings.ContainsAny(prefix, ", \t\n") || !unicode.IsLetter([]rune(prefix)[0]) {
+		return "", message, true
+	}
+
+	return prefix, message[separatorIdx+1:], prefix != reservedCategoryName
+}
+
+// "30m", "24h", "7d", "2w" or the same with Russian units ("24ч")
+var banDurationRegexp = regexp.MustCompile(`^(\d+)(m|h|d|w|м|ч|д|н)$`)
+
+var banDurationUnits = map[string]time.Duration{
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"м": time.Minute,
+	"ч": time.Hour,
+	"д": 24 * time.Hour,
+	"н": 7 * 24 * time.Hour,
+}
+
+func parseBanDuration(text string) (duration time.Duration, ok bool) {
+	match := banDurationRegexp.FindStringSubmatch(strings.ToLower(text))
+	if match == nil {
+		return 0, false
+	}
+
+	count, err := strconv.Atoi(match[1])
+	if err != nil || count < 1 {
+		return 0, false
+	}
+	return time.Duration(count) * banDurationUnits[match[2]], true
+}
+
+// "word1, word2 24h" makes the words prohibited only for the given time
+func parseWordsDuration(wordsList string) (words string, duration time.Duration) {
+	wordsList = strings.TrimSpace(wordsList)
+	durationIdx := strings.LastIndexAny(wordsList, " \t\n")
+	if durationIdx == -1 {
+		return wordsList, 0
+	}
+
+	duration, ok := parseBanDuration(wordsList[durationIdx+1:])
+	if !ok {
+		return wordsList, 0
+	}
+	return wordsList[:durationIdx], duration
+}
+
 func addWordCommand(data *processing.ProcessData) {
 	if !isSenderAnAdmin(data) {
-		data.Static.Chat.SendMessage(data.ChatId, data.Static.Trans("no_authority"))
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	category, wordsList, ok := parseCategoryPrefix(strings.TrimSpace(data.Message))
+	if !ok {
+		data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("reserved_category_name"), category))
+		return
+	}
+	wordsList, duration := parseWordsDuration(wordsList)
+
+	var expiresAt int64
+	if duration > 0 {
+		expiresAt = time.Now().Add(duration).Unix()
+	}
+
+	var categoryId int64 = -1
+	if len(category) > 0 {
+		categoryId = data.Static.Db.GetOrCreateCategory(data.ChatId, category)
+	}
+
+	words := strings.Split(wordsList, ",")
+	addedCount := 0
+	rejectedCount := 0
+
+	for _, word := range words {
+		patternType, pattern := parseWordParameter(word)
+		if len(pattern) > 1 {
+			// check that the pattern can be used before storing it
+			_, err := matching.MakeMatcher(patternType, pattern, matching.Normalizer{})
+			// patterns are matched against single tokens only
+			if err == nil && patternType != matching.ExactPattern && len(strings.Fields(pattern)) > 1 {
+				err = fmt.Errorf("pattern contains spaces")
+			}
+			if err != nil {
+				data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("wrong_pattern"), pattern))
+				rejectedCount++
+				continue
+			}
+			if expiresAt != 0 {
+				data.Static.Db.AddTemporaryProhibitedWord(data.ChatId, pattern, int(patternType), expiresAt)
+			} else {
+				data.Static.Db.AddProhibitedWord(data.ChatId, pattern, int(patternType))
+			}
+			if categoryId != -1 {
+				data.Static.Db.SetProhibitedWordCategory(data.ChatId, pattern, categoryId)
+			}
+			addedCount++
+		}
+	}
+
+	// rejected patterns are already reported
+	if addedCount == 0 {
+		if rejectedCount == 0 {
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_count"))
+		}
+		return
+	}
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func removeWordCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	words := strings.Split(data.Message, ",")
+
+	for _, word := range words {
+		_, pattern := parseWordParameter(word)
+		data.Static.Db.RemoveProhibitedWord(data.ChatId, pattern)
+	}
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func stemmingCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	isEnabled, ok := parseSwitchValue(data.Message)
+	if !ok {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_switch_value"))
+		return
+	}
+
+	setChatBoolSetting(data.Static, data.ChatId, stemmingSetting, isEnabled)
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func transliterationCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	isEnabled, ok := parseSwitchValue(data.Message)
+	if !ok {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_switch_value"))
+		return
+	}
+
+	setChatBoolSetting(data.Static, data.ChatId, transliterationSetting, isEnabled)
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func wordStemmingCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	// "/word_stemming on word1, word2"
+	parameters := strings.SplitN(strings.TrimSpace(data.Message), " ", 2)
+	if len(parameters) < 2 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_switch_value"))
+		return
+	}
+
+	stemming := database.WordStemmingDefault
+	if strings.ToLower(parameters[0]) != "default" {
+		isEnabled, ok := parseSwitchValue(parameters[0])
+		if !ok {
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_switch_value"))
+			return
+		}
+
+		if isEnabled {
+			stemming = database.WordStemmingEnabled
+		} else {
+			stemming = database.WordStemmingDisabled
+		}
+	}
+
+	for _, word := range strings.Split(parameters[1], ",") {
+		_, pattern := parseWordParameter(word)
+		data.Static.Db.SetProhibitedWordStemming(data.ChatId, pattern, stemming)
+	}
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+type wordOption struct {
+	// name used in the /word_options command and in the list of words
+	name string
+	flag int
+	// the option that is turned off when this one is turned on
+	excludedFlag int
+}
+
+var wordOptions = []wordOption{
+	{name: "case", flag: database.WordOptionCaseSensitive},
+	{name: "substring", flag: database.WordOptionSubstring, excludedFlag: database.WordOptionWholeToken},
+	{name: "whole", flag: database.WordOptionWholeToken, excludedFlag: database.WordOptionSubstring},
+}
+
+func wordOptionsCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	// "/word_options case on IT, Word"
+	parameters := strings.SplitN(strings.TrimSpace(data.Message), " ", 3)
+	if len(parameters) < 3 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_word_options"))
+		return
+	}
+
+	isEnabled, ok := parseSwitchValue(parameters[1])
+	if !ok {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_switch_value"))
+		return
+	}
+
+	optionName := strings.ToLower(parameters[0])
+
+	for _, option := range wordOptions {
+		if option.name == optionName {
+			for _, word := range strings.Split(parameters[2], ",") {
+				_, pattern := parseWordParameter(word)
+				data.Static.Db.SetProhibitedWordOption(data.ChatId, pattern, option.flag, isEnabled)
+				if isEnabled && option.excludedFlag != 0 {
+					data.Static.Db.SetProhibitedWordOption(data.ChatId, pattern, option.excludedFlag, false)
+				}
+			}
+
+			delete(data.Static.CachedWords, data.ChatId)
+
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+			return
+		}
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_word_options"))
+}
+
+// "off" clears the schedule, otherwise it's parsed and stored in the canonical form
+func parseScheduleParameter(text string) (scheduleText string, ok bool) {
+	text = strings.TrimSpace(text)
+	if isEnabled, isSwitch := parseSwitchValue(text); isSwitch && !isEnabled {
+		return "", true
+	}
+
+	parsedSchedule, err := schedule.Parse(text)
+	if err != nil {
+		return "", false
+	}
+	return parsedSchedule.String(), true
+}
+
+func scheduleCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	// "/schedule word1, word2 = mon-fri 9-18; sat,sun 22-6" or "/schedule word1, word2 = off"
+	separatorIdx := strings.LastIndex(data.Message, "=")
+	if separatorIdx == -1 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_schedule"))
+		return
+	}
+
+	scheduleText, ok := parseScheduleParameter(data.Message[separatorIdx+1:])
+	if !ok {
+		data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("wrong_schedule_value"), strings.TrimSpace(data.Message[separatorIdx+1:])))
+		return
+	}
+
+	for _, word := range strings.Split(data.Message[:separatorIdx], ",") {
+		_, pattern := parseWordParameter(word)
+		data.Static.Db.SetProhibitedWordSchedule(data.ChatId, pattern, scheduleText)
+	}
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func timezoneCommand(data *processing.ProcessData) {
+	timezone := strings.TrimSpace(data.Message)
+
+	// without parameters just show the current time zone
+	if len(timezone) == 0 {
+		location := getChatLocation(data.Static, data.ChatId)
+		data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("timezone_message"),
+			location.String(),
+			time.Now().In(location).Format("Mon 15:04"),
+		))
+		return
+	}
+
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	location, err := schedule.LoadLocation(timezone)
+	if err != nil {
+		data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("wrong_timezone"), timezone))
+		return
+	}
+
+	data.Static.Db.SetChatStringSetting(data.ChatId, timezoneSetting, location.String())
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func wordFuzzyCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	// "/word_fuzzy 2 word1, word2" or "/word_fuzzy off word1, word2"
+	parameters := strings.SplitN(strings.TrimSpace(data.Message), " ", 2)
+	if len(parameters) < 2 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_fuzzy_distance"))
+		return
+	}
+
+	distance := 0
+	if isEnabled, ok := parseSwitchValue(parameters[0]); !ok || isEnabled {
+		var err error
+		distance, err = strconv.Atoi(parameters[0])
+		if err != nil || distance < 1 {
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_fuzzy_distance"))
+			return
+		}
+	}
+
+	for _, word := range strings.Split(parameters[1], ",") {
+		_, pattern := parseWordParameter(word)
+		data.Static.Db.SetProhibitedWordFuzzy(data.ChatId, pattern, distance)
+	}
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func fuzzyHitsCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	count := 10
+	if len(strings.TrimSpace(data.Message)) > 0 {
+		var err error
+		count, err = strconv.Atoi(strings.TrimSpace(data.Message))
+		if err != nil || count < 1 {
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_count"))
+			return
+		}
+	}
+
+	hits := data.Static.Db.GetLastFuzzyHits(data.ChatId, count)
+	if len(hits) == 0 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_fuzzy_hits"))
+		return
+	}
+
+	var buffer bytes.Buffer
+
+	buffer.WriteString(data.Trans("fuzzy_hits_header"))
+
+	for _, hit := range hits {
+		buffer.WriteString(fmt.Sprintf("\n%s ≈ %s (%d) - %s",
+			hit.Surface,
+			hit.Word,
+			hit.Distance,
+			data.Static.Db.GetUserName(data.ChatId, hit.UserId),
+		))
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, buffer.String())
+}
+
+func normalizationCommand(data *processing.ProcessData) {
+	parameters := strings.Fields(data.Message)
+
+	// without parameters just show the current state
+	if len(parameters) == 0 {
+		var buffer bytes.Buffer
+
+		buffer.WriteString(data.Trans("normalization_header"))
+
+		for _, step := range normalizationSteps {
+			state := data.Trans("state_off")
+			if isNormalizationStepEnabled(data.Static, data.ChatId, step.setting) {
+				state = data.Trans("state_on")
+			}
+			buffer.WriteString(fmt.Sprintf("\n%s - %s (%s)", step.name, state, data.Trans("normalization_step_"+step.name)))
+		}
+
+		data.Static.Chat.SendMessage(data.ChatId, buffer.String())
+		return
+	}
+
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	if len(parameters) != 2 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_normalization_step"))
+		return
+	}
+
+	isEnabled, ok := parseSwitchValue(parameters[1])
+	if !ok {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_switch_value"))
+		return
+	}
+
+	for _, step := range normalizationSteps {
+		if step.name == strings.ToLower(parameters[0]) {
+			setChatBoolSetting(data.Static, data.ChatId, step.setting, isEnabled)
+			delete(data.Static.CachedWords, data.ChatId)
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+			return
+		}
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_normalization_step"))
+}
+
+func allowWordCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	for _, word := range strings.Split(data.Message, ",") {
+		allowedWord := strings.Join(strings.Fields(word), " ")
+		if len(allowedWord) > 1 {
+			data.Static.Db.AddAllowedWord(data.ChatId, allowedWord)
+		}
+	}
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func disallowWordCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	for _, word := range strings.Split(data.Message, ",") {
+		data.Static.Db.RemoveAllowedWord(data.ChatId, strings.Join(strings.Fields(word), " "))
+	}
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func setWeightCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	// "/set_weight word1, word2 5"
+	parameters := strings.TrimSpace(data.Message)
+	weightIdx := strings.LastIndexAny(parameters, " \t\n")
+	if weightIdx == -1 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_weight"))
 		return
 	}
 
-	words := strings.Split(data.Message, ",")
+	weight, err := strconv.Atoi(parameters[weightIdx+1:])
+	if err != nil || weight < 1 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_weight"))
+		return
+	}
+
+	for _, word := range strings.Split(parameters[:weightIdx], ",") {
+		_, pattern := parseWordParameter(word)
+		data.Static.Db.SetProhibitedWordWeight(data.ChatId, pattern, weight)
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func categoryCommand(data *processing.ProcessData) {
+	parameters := strings.Fields(data.Message)
+
+	// without parameters just show the list of categories
+	if len(parameters) == 0 {
+		var buffer bytes.Buffer
+
+		buffer.WriteString(data.Trans("categories_list_header"))
+
+		for _, category := range data.Static.Db.GetCategories(data.ChatId) {
+			buffer.WriteString("\n" + formatCategoryForList(data, category))
+		}
+
+		data.Static.Chat.SendMessage(data.ChatId, buffer.String())
+		return
+	}
+
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	// "/category name on|off", "/category name weight 5" or "/category name schedule sat,sun"
+	if len(parameters) < 2 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_category_parameters"))
+		return
+	}
+
+	categoryId := data.Static.Db.GetCategoryId(data.ChatId, strings.ToLower(parameters[0]))
+	if categoryId == -1 {
+		data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("unknown_category"), parameters[0]))
+		return
+	}
+
+	if strings.ToLower(parameters[1]) == "weight" {
+		if len(parameters) != 3 {
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_weight"))
+			return
+		}
+
+		weight, err := strconv.Atoi(parameters[2])
+		if err != nil || weight < 1 {
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_weight"))
+			return
+		}
+
+		data.Static.Db.SetCategoryWeight(categoryId, weight)
+	} else if strings.ToLower(parameters[1]) == "schedule" {
+		scheduleText, ok := parseScheduleParameter(strings.Join(parameters[2:], " "))
+		if !ok {
+			data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("wrong_schedule_value"), strings.Join(parameters[2:], " ")))
+			return
+		}
+
+		data.Static.Db.SetCategorySchedule(categoryId, scheduleText)
+		delete(data.Static.CachedWords, data.ChatId)
+	} else {
+		isEnabled, ok := parseSwitchValue(parameters[1])
+		if !ok || len(parameters) != 2 {
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_category_parameters"))
+			return
+		}
+
+		data.Static.Db.SetCategoryEnabled(categoryId, isEnabled)
+		delete(data.Static.CachedWords, data.ChatId)
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func formatCategoryForList(data *processing.ProcessData, category database.WordCategory) string {
+	state := data.Trans("state_off")
+	if category.IsEnabled {
+		state = data.Trans("state_on")
+	}
+	return fmt.Sprintf("%s (%s, ×%d)", category.Name, state, category.Weight) +
+		formatScheduleForList(data, category.Schedule, time.Now().In(getChatLocation(data.Static, data.ChatId)))
+}
+
+// shows when a temporary entry stops being prohibited
+func formatExpirationForList(data *processing.ProcessData, expiresAt int64, now time.Time) string {
+	if expiresAt == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s %s)", data.Trans("expires_at"), time.Unix(expiresAt, 0).In(now.Location()).Format("02.01.2006 15:04"))
+}
+
+// shows the schedule and whether it's active now, nothing for entries that are always active
+func formatScheduleForList(data *processing.ProcessData, scheduleText string, now time.Time) string {
+	if len(scheduleText) == 0 {
+		return ""
+	}
+
+	state := data.Trans("schedule_inactive")
+	if parsedSchedule, err := schedule.Parse(scheduleText); err != nil || parsedSchedule.IsActive(now) {
+		state = data.Trans("schedule_active")
+	}
+	return fmt.Sprintf(" {%s: %s}", scheduleText, state)
+}
+
+// word as it's shown in the list of words
+func formatWordForList(word database.ProhibitedWord) string {
+	formattedWord := fmt.Sprintf("'%s'", matching.FormatPattern(matching.PatternType(word.PatternType), word.Word))
+	if isPhrase(word) {
+		formattedWord = fmt.Sprintf("«%s»", word.Word)
+	}
+
+	if word.Weight != 1 {
+		formattedWord += fmt.Sprintf(" (%d)", word.Weight)
+	}
+
+	if word.FuzzyDistance > 0 {
+		formattedWord += fmt.Sprintf(" ≈%d", word.FuzzyDistance)
+	}
+
+	for _, option := range wordOptions {
+		if word.Options&option.flag != 0 {
+			formattedWord += fmt.Sprintf(" [%s]", option.name)
+		}
+	}
+
+	return formattedWord
+}
+
+func listOfWordsCommand(data *processing.ProcessData) {
+	var buffer bytes.Buffer
+
+	words := data.Static.Db.GetProhibitedWordsData(data.ChatId)
+	categories := data.Static.Db.GetCategories(data.ChatId)
+	now := time.Now().In(getChatLocation(data.Static, data.ChatId))
+
+	// schedules of words are shown when they differ from the schedules of their categories
+	writeCategoryWords := func(category database.WordCategory) {
+		for _, word := range words {
+			if word.CategoryId == category.Id {
+				buffer.WriteString(formatWordForList(word))
+				if word.Schedule != category.Schedule {
+					buffer.WriteString(formatScheduleForList(data, word.Schedule, now))
+				}
+				buffer.WriteString(formatExpirationForList(data, word.ExpiresAt, now))
+				buffer.WriteString(" ")
+			}
+		}
+	}
+
+	// "/words category" shows only words of one category
+	if categoryName := strings.ToLower(strings.TrimSpace(data.Message)); len(categoryName) > 0 {
+		for _, category := range categories {
+			if category.Name == categoryName {
+				buffer.WriteString(formatCategoryForList(data, category) + ":\n")
+				writeCategoryWords(category)
+				data.Static.Chat.SendMessage(data.ChatId, buffer.String())
+				return
+			}
+		}
+
+		data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("unknown_category"), categoryName))
+		return
+	}
+
+	buffer.WriteString(data.Trans("words_list_header") + "\n")
+
+	phrases := []string{}
+	stickers := []string{}
+	domains := []string{}
+
+	for _, word := range words {
+		scheduleState := formatScheduleForList(data, word.Schedule, now) + formatExpirationForList(data, word.ExpiresAt, now)
+
+		switch word.Kind {
+		case database.WordKindSticker:
+			stickers = append(stickers, word.Word+scheduleState)
+			continue
+		case database.WordKindStickerSet:
+			stickers = append(stickers, fmt.Sprintf(data.Trans("sticker_set_list_item"), word.Word)+scheduleState)
+			continue
+		case database.WordKindDomain:
+			domains = append(domains, word.Word+scheduleState)
+			continue
+		}
+
+		if word.CategoryId != -1 {
+			continue
+		}
+
+		if isPhrase(word) {
+			phrases = append(phrases, formatWordForList(word)+scheduleState)
+		} else {
+			buffer.WriteString(formatWordForList(word) + scheduleState + " ")
+		}
+	}
+
+	if len(phrases) > 0 {
+		buffer.WriteString("\n\n" + data.Trans("phrases_list_header"))
+		for _, phrase := range phrases {
+			buffer.WriteString("\n" + phrase)
+		}
+	}
+
+	if len(domains) > 0 {
+		buffer.WriteString("\n\n" + data.Trans("domains_list_header") + "\n")
+		buffer.WriteString(strings.Join(domains, " "))
+	}
+
+	if len(stickers) > 0 {
+		buffer.WriteString("\n\n" + data.Trans("stickers_list_header"))
+		for _, sticker := range stickers {
+			buffer.WriteString("\n" + sticker)
+		}
+	}
+
+	for _, category := range categories {
+		buffer.WriteString("\n\n" + formatCategoryForList(data, category) + ":\n")
+		writeCategoryWords(category)
+	}
+
+	allowedWords := data.Static.Db.GetAllowedWords(data.ChatId)
+
+	if len(allowedWords) > 0 {
+		buffer.WriteString("\n\n" + data.Trans("allowed_words_list_header") + "\n")
+		for _, allowedWord := range allowedWords {
+			buffer.WriteString(fmt.Sprintf("'%s' ", allowedWord))
+		}
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, buffer.String())
+}
+
+// "category all" -> "category", true
+func parseScoreParameters(message string) (categoryName string, includeAbsent bool) {
+	parts := strings.Fields(strings.ToLower(message))
+	if len(parts) > 0 && parts[len(parts)-1] == reservedCategoryName {
+		includeAbsent = true
+		parts = parts[:len(parts)-1]
+	}
+	categoryName = strings.Join(parts, " ")
+	return
+}
+
+func playerScoresCommand(data *processing.ProcessData) {
+	var buffer bytes.Buffer
+
+	var names []string
+	var scores []int
+
+	// "/score all" shows also users that left the chat
+	categoryName, includeAbsent := parseScoreParameters(data.Message)
+
+	// "/score category" shows only fines for words of one category
+	if len(categoryName) > 0 {
+		categoryId := data.Static.Db.GetCategoryId(data.ChatId, categoryName)
+		if categoryId == -1 {
+			data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("unknown_category"), categoryName))
+			return
+		}
+
+		buffer.WriteString(fmt.Sprintf(data.Trans("category_users_list_header"), categoryName))
+		_, names, scores = data.Static.Db.GetCategoryUsersList(data.ChatId, categoryId, includeAbsent)
+	} else {
+		buffer.WriteString(data.Trans("users_list_header"))
+		_, names, scores = data.Static.Db.GetUsersList(data.ChatId, includeAbsent)
+	}
+
+	for idx, name := range names {
+		score := scores[idx]
+		buffer.WriteString(fmt.Sprintf("\n%s - %d", name, score))
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, buffer.String())
+}
+
+const (
+	statsTopWordsCount     = 10
+	statsUserTopWordsCount = 3
+	statsUsersPerPage      = 10
+)
+
+type statsPeriod struct {
+	// name used in translations
+	name     string
+	duration time.Duration
+}
+
+// 0 means all the time
+var statsPeriods = []statsPeriod{
+	{name: "day", duration: 24 * time.Hour},
+	{name: "week", duration: 7 * 24 * time.Hour},
+	{name: "month", duration: 30 * 24 * time.Hour},
+	{name: "all", duration: 0},
+}
+
+// returns the range of items on the page, pages start from 1
+func getPageRange(itemsCount int, pageSize int, page int) (from int, to int, pagesCount int) {
+	pagesCount = (itemsCount + pageSize - 1) / pageSize
+	if pagesCount == 0 {
+		pagesCount = 1
+	}
+
+	from = minInt((page-1)*pageSize, itemsCount)
+	to = minInt(from+pageSize, itemsCount)
+	return
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func formatWordCounts(words []database.WordCount) string {
+	formattedWords := []string{}
+	for _, word := range words {
+		formattedWords = append(formattedWords, fmt.Sprintf("%s ×%d", word.Word, word.Count))
+	}
+	return strings.Join(formattedWords, ", ")
+}
+
+// "/stats" shows the totals and the first users, "/stats 2" shows the next users
+func statsCommand(data *processing.ProcessData) {
+	page := 1
+	if pageText := strings.TrimSpace(data.Message); len(pageText) > 0 {
+		var err error
+		page, err = strconv.Atoi(pageText)
+		if err != nil || page < 1 {
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_page"))
+			return
+		}
+	}
+
+	ids, names, _ := data.Static.Db.GetUsersList(data.ChatId, false)
+	from, to, pagesCount := getPageRange(len(ids), statsUsersPerPage, page)
+	if page > pagesCount {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_page"))
+		return
+	}
+
+	var buffer bytes.Buffer
+
+	if page == 1 {
+		if data.Static.Db.GetUsageCounts(data.ChatId, 0) == (database.UsageCounts{}) {
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_stats"))
+			return
+		}
+
+		buffer.WriteString(data.Trans("stats_header"))
+
+		now := time.Now()
+		for _, period := range statsPeriods {
+			var periodStart int64
+			if period.duration > 0 {
+				periodStart = now.Add(-period.duration).Unix()
+			}
+
+			counts := data.Static.Db.GetUsageCounts(data.ChatId, periodStart)
+			buffer.WriteString(fmt.Sprintf("\n"+data.Trans("stats_usage_count"), data.Trans("stats_period_"+period.name), counts.Count, counts.RevokedCount))
+		}
+
+		buffer.WriteString("\n\n" + data.Trans("stats_top_words_header"))
+		for idx, word := range data.Static.Db.GetTopWords(data.ChatId, 0, statsTopWordsCount) {
+			buffer.WriteString(fmt.Sprintf("\n%d. %s - %d", idx+1, word.Word, word.Count))
+		}
+
+		buffer.WriteString("\n\n")
+	}
+
+	buffer.WriteString(data.Trans("stats_users_header"))
+	for idx := from; idx < to; idx++ {
+		// users with only revoked words have nothing to show
+		if words := data.Static.Db.GetTopWords(data.ChatId, ids[idx], statsUserTopWordsCount); len(words) > 0 {
+			buffer.WriteString(fmt.Sprintf("\n%s: %s", names[idx], formatWordCounts(words)))
+		}
+	}
+
+	if pagesCount > 1 {
+		buffer.WriteString("\n\n" + fmt.Sprintf(data.Trans("stats_page"), page, pagesCount))
+		if page < pagesCount {
+			buffer.WriteString(fmt.Sprintf(data.Trans("stats_next_page"), page+1))
+		}
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, buffer.String())
+}
+
+func amnestyLastWords(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	count, err := strconv.Atoi(data.Message)
+	if err != nil || count < 1 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_count"))
+		return
+	}
+
+	words, userId := data.Static.Db.RevokeLastUsedWords(data.ChatId, count, data.UserId)
+
+	if len(words) <= 0 && userId == -1 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_words_amnestied"))
+		return
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId,
+		fmt.Sprintf(data.Trans("amnestied_words_header"),
+			data.Static.Db.GetUserName(data.ChatId, userId),
+			strings.Join(words, ", "),
+		),
+	)
+}
+
+func entitiesCommand(data *processing.ProcessData) {
+	parameters := strings.Fields(data.Message)
+
+	// without parameters just show the current state
+	if len(parameters) == 0 {
+		var buffer bytes.Buffer
+
+		buffer.WriteString(data.Trans("entities_header"))
+
+		excludedEntities := getExcludedEntities(data.Static, data.ChatId)
+
+		for _, kind := range entityKinds {
+			state := data.Trans("state_off")
+			if excludedEntities[kind.name] {
+				state = data.Trans("state_on")
+			}
+			buffer.WriteString(fmt.Sprintf("\n%s - %s (%s)", kind.name, state, data.Trans("entity_"+kind.name)))
+		}
+
+		data.Static.Chat.SendMessage(data.ChatId, buffer.String())
+		return
+	}
+
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	if len(parameters) != 2 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_entity_kind"))
+		return
+	}
+
+	isExcluded, ok := parseSwitchValue(parameters[1])
+	if !ok {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_switch_value"))
+		return
+	}
+
+	for _, kind := range entityKinds {
+		if kind.name == strings.ToLower(parameters[0]) {
+			setChatBoolSetting(data.Static, data.ChatId, getEntitySettingName(kind.name), isExcluded)
+			delete(data.Static.CachedWords, data.ChatId)
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+			return
+		}
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_entity_kind"))
+}
+
+// "/ban_sticker" as a reply to a sticker bans the sticker, "/ban_sticker set" bans its whole set
+func getRepliedStickerEntry(data *processing.ProcessData) (word string, kind int, ok bool) {
+	if data.ReplyToSticker == nil {
+		return
+	}
+
+	if strings.ToLower(strings.TrimSpace(data.Message)) == "set" {
+		return data.ReplyToSticker.SetName, database.WordKindStickerSet, len(data.ReplyToSticker.SetName) > 0
+	} else {
+		return data.ReplyToSticker.Id, database.WordKindSticker, len(data.ReplyToSticker.Id) > 0
+	}
+}
+
+func banStickerCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	word, kind, ok := getRepliedStickerEntry(data)
+	if !ok {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_replied_sticker"))
+		return
+	}
+
+	data.Static.Db.AddProhibitedWord(data.ChatId, word, int(matching.ExactPattern))
+	data.Static.Db.SetProhibitedWordKind(data.ChatId, word, kind)
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func unbanStickerCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	word, _, ok := getRepliedStickerEntry(data)
+	if !ok {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_replied_sticker"))
+		return
+	}
+
+	data.Static.Db.RemoveProhibitedWord(data.ChatId, word)
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func banDomainCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	for _, link := range strings.Split(data.Message, ",") {
+		if len(strings.TrimSpace(link)) == 0 {
+			continue
+		}
+
+		domain := matching.GetLinkDomain(link)
+		if len(domain) == 0 {
+			data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("wrong_domain"), strings.TrimSpace(link)))
+			continue
+		}
+
+		data.Static.Db.AddProhibitedWord(data.ChatId, domain, int(matching.ExactPattern))
+		data.Static.Db.SetProhibitedWordKind(data.ChatId, domain, database.WordKindDomain)
+	}
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func unbanDomainCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	for _, link := range strings.Split(data.Message, ",") {
+		if domain := matching.GetLinkDomain(link); len(domain) > 0 {
+			data.Static.Db.RemoveProhibitedWord(data.ChatId, domain)
+		}
+	}
+
+	delete(data.Static.CachedWords, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+// "/exempt @name category:politics" exempts the user from all the words of the category
+const exemptionCategoryPrefix = "category:"
+
+// "/command @name ..." or "/command ..." as a reply to a message of the user
+func getTargetUser(data *processing.ProcessData) (userId int64, parameters string, ok bool) {
+	message := strings.TrimSpace(data.Message)
+
+	if strings.HasPrefix(message, "@") {
+		parts := strings.SplitN(message, " ", 2)
+		userId = data.Static.Db.GetUserIdByName(data.ChatId, parts[0][1:])
+		if userId == -1 {
+			data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("unknown_user"), parts[0]))
+			return -1, "", false
+		}
+		if len(parts) > 1 {
+			parameters = parts[1]
+		}
+		return userId, parameters, true
+	}
+
+	if data.ReplyToUserId == 0 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_target_user"))
+		return -1, "", false
+	}
+
+	// the user may have never been fined, the name is needed for the list of exemptions,
+	// users without fines are not shown in the scores
+	data.Static.Db.UpdateUser(data.ChatId, data.ReplyToUserId, data.ReplyToUserName)
+	return data.ReplyToUserId, message, true
+}
+
+// "word1, category:name" or nothing for all the words
+func parseExemptions(data *processing.ProcessData, userId int64, parameters string) (exemptions []database.Exemption, ok bool) {
+	if len(strings.TrimSpace(parameters)) == 0 {
+		return []database.Exemption{{UserId: userId, CategoryId: -1}}, true
+	}
+
+	for _, item := range strings.Split(parameters, ",") {
+		item = strings.TrimSpace(item)
+		if strings.HasPrefix(strings.ToLower(item), exemptionCategoryPrefix) {
+			categoryName := strings.ToLower(strings.TrimSpace(item[len(exemptionCategoryPrefix):]))
+			categoryId := data.Static.Db.GetCategoryId(data.ChatId, categoryName)
+			if categoryId == -1 {
+				data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("unknown_category"), categoryName))
+				return nil, false
+			}
+			exemptions = append(exemptions, database.Exemption{UserId: userId, CategoryId: categoryId})
+		} else if _, pattern := parseWordParameter(item); len(pattern) > 0 {
+			exemptions = append(exemptions, database.Exemption{UserId: userId, Word: pattern, CategoryId: -1})
+		}
+	}
+	return exemptions, true
+}
+
+func exemptCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	userId, parameters, ok := getTargetUser(data)
+	if !ok {
+		return
+	}
+
+	exemptions, ok := parseExemptions(data, userId, parameters)
+	if !ok {
+		return
+	}
+
+	for _, exemption := range exemptions {
+		data.Static.Db.AddExemption(data.ChatId, exemption)
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func unexemptCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	userId, parameters, ok := getTargetUser(data)
+	if !ok {
+		return
+	}
+
+	// without parameters all the exemptions of the user are removed
+	if len(strings.TrimSpace(parameters)) == 0 {
+		data.Static.Db.RemoveUserExemptions(data.ChatId, userId)
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+		return
+	}
+
+	exemptions, ok := parseExemptions(data, userId, parameters)
+	if !ok {
+		return
+	}
+
+	for _, exemption := range exemptions {
+		data.Static.Db.RemoveExemption(data.ChatId, exemption)
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func formatExemption(data *processing.ProcessData, exemption database.Exemption, categoryNames map[int64]string) string {
+	if exemption.CategoryId != -1 {
+		return fmt.Sprintf(data.Trans("exemption_category"), categoryNames[exemption.CategoryId])
+	} else if len(exemption.Word) > 0 {
+		return fmt.Sprintf("'%s'", exemption.Word)
+	} else {
+		return data.Trans("exemption_all")
+	}
+}
+
+func exemptionsCommand(data *processing.ProcessData) {
+	exemptions := data.Static.Db.GetExemptions(data.ChatId)
+	if len(exemptions) == 0 {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_exemptions"))
+		return
+	}
+
+	categoryNames := map[int64]string{}
+	for _, category := range data.Static.Db.GetCategories(data.ChatId) {
+		categoryNames[category.Id] = category.Name
+	}
+
+	var buffer bytes.Buffer
+
+	buffer.WriteString(data.Trans("exemptions_header"))
+
+	// exemptions are sorted by users
+	for idx, exemption := range exemptions {
+		if idx == 0 || exemptions[idx-1].UserId != exemption.UserId {
+			buffer.WriteString(fmt.Sprintf("\n%s: ", data.Static.Db.GetUserName(data.ChatId, exemption.UserId)))
+		} else {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString(formatExemption(data, exemption, categoryNames))
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, buffer.String())
+}
+
+const (
+	// time for the admin to confirm a reset of scores
+	scoresResetConfirmationTime = time.Minute
+	// time to change the mind after a reset of scores
+	scoresResetUndoTime = 10 * time.Minute
+)
+
+// arguments of commands that can be written in the languages of the bot
+var localizedArguments = []string{"all", "word"}
+
+// translations of localizedArguments by languages, the English ones work in every chat
+var argumentAliases = map[string]map[string]string{
+	"ru-ru": {
+		"all":  "все",
+		"word": "слово",
+	},
+}
+
+func isArgument(text string, argument string) bool {
+	text = strings.ToLower(text)
+	if text == argument {
+		return true
+	}
+
+	for _, aliases := range argumentAliases {
+		if alias, ok := aliases[argument]; ok && alias == text {
+			return true
+		}
+	}
+	return false
+}
+
+// "/reset_scores all", "/reset_scores @name", "/reset_scores word name" or "/reset_scores" as a reply
+func parseScoresReset(data *processing.ProcessData) (reset *processing.ScoresReset, ok bool) {
+	reset = &processing.ScoresReset{
+		RequestedBy: data.UserId,
+		RequestedAt: time.Now(),
+	}
+
+	parameters := strings.SplitN(strings.TrimSpace(data.Message), " ", 2)
+	if isArgument(parameters[0], "all") {
+		return reset, true
+	} else if isArgument(parameters[0], "word") {
+		if len(parameters) > 1 {
+			if _, reset.Word = parseWordParameter(parameters[1]); len(reset.Word) > 0 {
+				return reset, true
+			}
+		}
+	} else if len(parameters[0]) == 0 || strings.HasPrefix(parameters[0], "@") {
+		userId, _, ok := getTargetUser(data)
+		if !ok {
+			return nil, false
+		}
+		reset.UserId = userId
+		return reset, true
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_reset_scope"))
+	return nil, false
+}
+
+func formatScoresResetScope(data *processing.ProcessData, reset *processing.ScoresReset) string {
+	if len(reset.Word) > 0 {
+		return fmt.Sprintf(data.Trans("reset_scope_word"), reset.Word)
+	} else if reset.UserId != 0 {
+		return fmt.Sprintf(data.Trans("reset_scope_user"), data.Static.Db.GetUserName(data.ChatId, reset.UserId))
+	} else {
+		return data.Trans("reset_scope_everyone")
+	}
+}
+
+func confirmScoresReset(data *processing.ProcessData) {
+	reset, ok := data.Static.PendingResets[data.ChatId]
+	if !ok || reset.RequestedBy != data.UserId || time.Since(reset.RequestedAt) > scoresResetConfirmationTime {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_scores_reset_to_confirm"))
+		return
+	}
+
+	delete(data.Static.PendingResets, data.ChatId)
+
+	data.Static.Db.ResetScores(data.ChatId, reset.UserId, reset.Word)
+
+	data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("scores_reset_done"),
+		formatScoresResetScope(data, reset),
+		int(scoresResetUndoTime.Minutes()),
+	))
+}
+
+func undoScoresReset(data *processing.ProcessData) {
+	resetId, createdAt := data.Static.Db.GetLastScoresReset(data.ChatId)
+	if resetId == -1 || time.Since(time.Unix(createdAt, 0)) > scoresResetUndoTime {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_scores_reset_to_undo"))
+		return
+	}
+
+	data.Static.Db.UndoScoresReset(data.ChatId, resetId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func resetScoresCommand(data *processing.ProcessData) {
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(data.Message)) {
+	case "confirm":
+		confirmScoresReset(data)
+		return
+	case "undo":
+		undoScoresReset(data)
+		return
+	}
+
+	reset, ok := parseScoresReset(data)
+	if !ok {
+		return
+	}
+
+	// only the last requested reset can be confirmed
+	data.Static.PendingResets[data.ChatId] = reset
+
+	data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("scores_reset_confirmation"),
+		formatScoresResetScope(data, reset),
+		int(scoresResetConfirmationTime.Seconds()),
+	))
+}
+
+func languageCommand(data *processing.ProcessData) {
+	languageName := strings.TrimSpace(data.Message)
+
+	// without parameters just show the current language
+	if len(languageName) == 0 {
+		data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("language_message"),
+			getChatLanguage(data.Static, data.ChatId),
+			strings.Join(i18n.LanguageTags(), ", "),
+		))
+		return
+	}
+
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	language, ok := findLanguage(languageName)
+	if !ok {
+		data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("wrong_language"), languageName, strings.Join(i18n.LanguageTags(), ", ")))
+		return
+	}
+
+	data.Static.Db.SetChatStringSetting(data.ChatId, languageSetting, language)
+
+	// the answer is already in the new language
+	data.Trans = getChatTrans(data.Static, data.ChatId)
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+}
+
+func forwardsCommand(data *processing.ProcessData) {
+	policy := strings.ToLower(strings.TrimSpace(data.Message))
+
+	// without parameters just show the current policy
+	if len(policy) == 0 {
+		currentPolicy := getForwardPolicy(data.Static, data.ChatId)
+		data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf(data.Trans("forward_policy_message"),
+			currentPolicy,
+			data.Trans("forward_policy_"+currentPolicy),
+		))
+		return
+	}
+
+	if !isSenderAnAdmin(data) {
+		data.Static.Chat.SendMessage(data.ChatId, data.Trans("no_authority"))
+		return
+	}
+
+	for _, knownPolicy := range forwardPolicies {
+		if policy == knownPolicy {
+			data.Static.Db.SetChatStringSetting(data.ChatId, forwardPolicySetting, policy)
+			data.Static.Chat.SendMessage(data.ChatId, data.Trans("success_message"))
+			return
+		}
+	}
+
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("wrong_forward_policy"))
+}
+
+func makeUserCommandProcessors() ProcessorFuncMap {
+	return map[string]ProcessorFunc{
+		"add_word":        addWordCommand,
+		"remove_word":     removeWordCommand,
+		"words":           listOfWordsCommand,
+		"score":           playerScoresCommand,
+		"amnesty":         amnestyLastWords,
+		"stemming":        stemmingCommand,
+		"word_stemming":   wordStemmingCommand,
+		"normalization":   normalizationCommand,
+		"allow_word":      allowWordCommand,
+		"set_weight":      setWeightCommand,
+		"disallow_word":   disallowWordCommand,
+		"category":        categoryCommand,
+		"forwards":        forwardsCommand,
+		"entities":        entitiesCommand,
+		"transliteration": transliterationCommand,
+		"word_fuzzy":      wordFuzzyCommand,
+		"word_options":    wordOptionsCommand,
+		"fuzzy_hits":      fuzzyHitsCommand,
+		"ban_sticker":     banStickerCommand,
+		"unban_sticker":   unbanStickerCommand,
+		"ban_domain":      banDomainCommand,
+		"unban_domain":    unbanDomainCommand,
+		"schedule":        scheduleCommand,
+		"timezone":        timezoneCommand,
+		"exempt":          exemptCommand,
+		"unexempt":        unexemptCommand,
+		"exemptions":      exemptionsCommand,
+		"reset_scores":    resetScoresCommand,
+		"language":        languageCommand,
+		"stats":           statsCommand,
+	}
+}
+
+func processCommandByProcessors(data *processing.ProcessData, processorsMap ProcessorFuncMap) bool {
+	processor, ok := processorsMap[data.Command]
+	if ok {
+		processor(data)
+	}
+
+	return ok
+}
+
+func processCommand(data *processing.ProcessData, processors *Processors) {
+	processed := processCommandByProcessors(data, processors.Main)
+	if processed {
+		return
+	}
+
+	// if we here it means that no command was processed
+	data.Static.Chat.SendMessage(data.ChatId, data.Trans("warn_unknown_command"))
+}
+
+func getUserName(message *tgbotapi.Message) string {
+	user := message.From
+	if user != nil {
+		if len(user.UserName) > 0 {
+			return user.UserName
+		} else {
+			return user.FirstName
+		}
+	} else {
+		return "unknown"
+	}
+}
+
+// characters that are dropped inside words ("don't", "den'gi") instead of splitting them
+const wordApostrophes = "'’ʼ`"
+
+// splits text by everything that is not a letter or a digit,
+// so hyphenated compounds become several tokens and are matched as phrases.
+// separators[i] is the text between tokens[i-1] and tokens[i]
+func splitToTokensWithSeparators(text string) (tokens []string, separators []string) {
+	var token strings.Builder
+	var separator strings.Builder
+
+	runes := []rune(text)
+	for i, r := range runes {
+		if matching.IsTokenRune(r) {
+			if token.Len() == 0 {
+				separators = append(separators, separator.String())
+				separator.Reset()
+			}
+			token.WriteRune(r)
+			continue
+		}
+
+		if strings.ContainsRune(wordApostrophes, r) && token.Len() > 0 && i+1 < len(runes) && matching.IsTokenRune(runes[i+1]) {
+			continue
+		}
+
+		if token.Len() > 0 {
+			tokens = append(tokens, token.String())
+			token.Reset()
+		}
+		separator.WriteRune(r)
+	}
+
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+
+	return
+}
+
+func splitToTokens(text string) []string {
+	tokens, _ := splitToTokensWithSeparators(text)
+	return tokens
+}
+
+func makeTokens(text string, normalizer matching.Normalizer) (tokens []matching.Token) {
+	textWords, separators := splitToTokensWithSeparators(normalizer.NormalizeText(text))
+	for i, textWord := range textWords {
+		token := matching.MakeToken(textWord, normalizer)
+		token.Separator = separators[i]
+		tokens = append(tokens, token)
+	}
+	return
+}
+
+func findWords(text string, scanner *matching.Scanner, normalizer matching.Normalizer) (foundWords []matching.WordMatch) {
+	return scanner.FindMatches(makeTokens(text, normalizer))
+}
 
-	for _, word := range words {
-		trimmedWord := strings.Trim(word, " \t\n")
-		if len(trimmedWord) > 1 {
-			data.Static.Db.AddProhibitedWord(data.ChatId, trimmedWord)
+// the key that is used to compare matched text with allowed words
+func getAllowedWordKey(text string, normalizer matching.Normalizer) string {
+	normalizedTokens := []string{}
+	for _, token := range makeTokens(text, normalizer) {
+		normalizedTokens = append(normalizedTokens, token.Normalized)
+	}
+	return strings.Join(normalizedTokens, " ")
+}
+
+func makeAllowedWordsSet(allowedWords []string, normalizer matching.Normalizer) map[string]bool {
+	allowedWordsSet := map[string]bool{}
+	for _, allowedWord := range allowedWords {
+		allowedWordsSet[getAllowedWordKey(allowedWord, normalizer)] = true
+	}
+	return allowedWordsSet
+}
+
+func removeAllowedWords(foundWords []matching.WordMatch, allowedWords map[string]bool, normalizer matching.Normalizer) (filteredWords []matching.WordMatch) {
+	for _, foundWord := range foundWords {
+		if !allowedWords[getAllowedWordKey(foundWord.Surface, normalizer)] {
+			filteredWords = append(filteredWords, foundWord)
 		}
 	}
+	return
+}
 
-	delete(data.Static.CachedWords, data.ChatId)
+func isPhrase(word database.ProhibitedWord) bool {
+	return matching.PatternType(word.PatternType) == matching.ExactPattern && len(splitToTokens(word.Word)) > 1
+}
 
-	data.Static.Chat.SendMessage(data.ChatId, data.Static.Trans("success_message"))
+func getWordMatchOptions(word database.ProhibitedWord) matching.MatchOptions {
+	return matching.MatchOptions{
+		CaseSensitive: word.Options&database.WordOptionCaseSensitive != 0,
+		Substring:     word.Options&database.WordOptionSubstring != 0 && word.Options&database.WordOptionWholeToken == 0,
+	}
 }
 
-func removeWordCommand(data *processing.ProcessData) {
-	if !isSenderAnAdmin(data) {
-		data.Static.Chat.SendMessage(data.ChatId, data.Static.Trans("no_authority"))
-		return
+// words with options are matched only the way the options say
+func isWordStemmingEnabled(word database.ProhibitedWord, isChatStemmingEnabled bool) bool {
+	if matching.PatternType(word.PatternType) != matching.ExactPattern || word.Options != 0 {
+		return false
 	}
 
-	words := strings.Split(data.Message, ",")
+	switch word.Stemming {
+	case database.WordStemmingEnabled:
+		return true
+	case database.WordStemmingDisabled:
+		return false
+	default:
+		return isChatStemmingEnabled
+	}
+}
+
+func makePhraseMatcher(word database.ProhibitedWord, isStemmingEnabled bool, normalizer matching.Normalizer) matching.Matcher {
+	parts := []matching.Matcher{}
+	for _, part := range splitToTokens(word.Word) {
+		if isStemmingEnabled {
+			parts = append(parts, matching.MakeStemmingMatcher(part, normalizer))
+		} else {
+			// exact matchers can't fail, phrases are always matched by whole tokens
+			partMatcher, _ := matching.MakeMatcherWithOptions(matching.ExactPattern, part, normalizer, matching.MatchOptions{
+				CaseSensitive: getWordMatchOptions(word).CaseSensitive,
+			})
+			parts = append(parts, partMatcher)
+		}
+	}
+	return matching.MakePhraseMatcher(word.Word, parts)
+}
 
+// transliterations are matched exactly even for words with stemming
+func makeMatchers(words []database.ProhibitedWord, isChatStemmingEnabled bool, isTransliterationEnabled bool, normalizer matching.Normalizer) (matchers []matching.Matcher) {
 	for _, word := range words {
-		data.Static.Db.RemoveProhibitedWord(data.ChatId, strings.Trim(word, " \t\n"))
+		if isTransliterationEnabled && matching.PatternType(word.PatternType) == matching.ExactPattern && word.Options == 0 {
+			matchers = append(matchers, matching.MakeTransliteratedMatchers(word.Word, normalizer)...)
+		}
+
+		if isPhrase(word) {
+			matchers = append(matchers, makePhraseMatcher(word, isWordStemmingEnabled(word, isChatStemmingEnabled), normalizer))
+			continue
+		}
+
+		if word.FuzzyDistance > 0 && matching.PatternType(word.PatternType) == matching.ExactPattern && word.Options == 0 {
+			// too short words are not matched fuzzily
+			if fuzzyMatcher := matching.MakeFuzzyMatcher(word.Word, word.FuzzyDistance, normalizer); fuzzyMatcher != nil {
+				matchers = append(matchers, fuzzyMatcher)
+			}
+		}
+
+		if isWordStemmingEnabled(word, isChatStemmingEnabled) {
+			matchers = append(matchers, matching.MakeStemmingMatcher(word.Word, normalizer))
+			continue
+		}
+
+		matcher, err := matching.MakeMatcherWithOptions(matching.PatternType(word.PatternType), word.Word, normalizer, getWordMatchOptions(word))
+		if err != nil {
+			// patterns are validated before adding, so it's an old or broken record
+			log.Printf("Can't use prohibited word '%s': %s", word.Word, err.Error())
+			continue
+		}
+		matchers = append(matchers, matcher)
 	}
+	return
+}
 
-	delete(data.Static.CachedWords, data.ChatId)
+func removeDisabledCategoryWords(words []database.ProhibitedWord, categories []database.WordCategory) (enabledWords []database.ProhibitedWord) {
+	disabledCategories := map[int64]bool{}
+	for _, category := range categories {
+		if !category.IsEnabled {
+			disabledCategories[category.Id] = true
+		}
+	}
 
-	data.Static.Chat.SendMessage(data.ChatId, data.Static.Trans("success_message"))
+	for _, word := range words {
+		if !disabledCategories[word.CategoryId] {
+			enabledWords = append(enabledWords, word)
+		}
+	}
+	return
 }
 
-func listOfWordsCommand(data *processing.ProcessData) {
-	var buffer bytes.Buffer
+func makeScanner(words []database.ProhibitedWord, isChatStemmingEnabled bool, isTransliterationEnabled bool, normalizer matching.Normalizer) *matching.Scanner {
+	return matching.MakeScanner(makeMatchers(words, isChatStemmingEnabled, isTransliterationEnabled, normalizer))
+}
 
-	buffer.WriteString(data.Static.Trans("words_list_header") + "\n")
+// takes out the entries that are not matched against message tokens, returns the rest
+func separateSpecialEntries(words []database.ProhibitedWord, chatWords *processing.ChatWords) (textWords []database.ProhibitedWord) {
+	chatWords.Emoji = map[string]string{}
+	chatWords.Stickers = map[string]bool{}
+	chatWords.StickerSets = map[string]bool{}
 
-	words := data.Static.Db.GetProhibitedWords(data.ChatId)
+	for _, word := range words {
+		switch {
+		case word.Kind == database.WordKindSticker:
+			chatWords.Stickers[word.Word] = true
+		case word.Kind == database.WordKindStickerSet:
+			chatWords.StickerSets[word.Word] = true
+		case word.Kind == database.WordKindDomain:
+			chatWords.Domains = append(chatWords.Domains, word.Word)
+		case matching.PatternType(word.PatternType) == matching.ExactPattern && matching.IsEmoji(word.Word):
+			chatWords.Emoji[matching.NormalizeEmoji(word.Word)] = word.Word
+		default:
+			textWords = append(textWords, word)
+		}
+	}
+	return
+}
 
+func makeWordCategories(words []database.ProhibitedWord) map[string]int64 {
+	wordCategories := map[string]int64{}
 	for _, word := range words {
-		buffer.WriteString(fmt.Sprintf("'%s' ", word))
+		if word.CategoryId != -1 {
+			wordCategories[word.Word] = word.CategoryId
+		}
 	}
+	return wordCategories
+}
 
-	data.Static.Chat.SendMessage(data.ChatId, buffer.String())
+// removes matches that the user is exempted from
+func removeExemptedWords(foundWords []matching.WordMatch, exemptions []database.Exemption, wordCategories map[string]int64) (newWords []matching.WordMatch) {
+	for _, foundWord := range foundWords {
+		isExempted := false
+		for _, exemption := range exemptions {
+			if exemption.CategoryId != -1 {
+				categoryId, ok := wordCategories[foundWord.Word]
+				isExempted = ok && categoryId == exemption.CategoryId
+			} else {
+				isExempted = len(exemption.Word) == 0 || exemption.Word == foundWord.Word
+			}
+
+			if isExempted {
+				break
+			}
+		}
+
+		if !isExempted {
+			newWords = append(newWords, foundWord)
+		}
+	}
+	return
 }
 
-func playerScoresCommand(data *processing.ProcessData) {
-	var buffer bytes.Buffer
+// broken schedules are ignored and the entries stay always active
+func makeWordSchedules(words []database.ProhibitedWord) map[string]schedule.Schedule {
+	schedules := map[string]schedule.Schedule{}
+	for _, word := range words {
+		if len(word.Schedule) == 0 {
+			continue
+		}
 
-	buffer.WriteString(data.Static.Trans("users_list_header"))
+		parsedSchedule, err := schedule.Parse(word.Schedule)
+		if err != nil {
+			log.Printf("Can't use schedule '%s' of word '%s': %s", word.Schedule, word.Word, err.Error())
+			continue
+		}
+		schedules[word.Word] = parsedSchedule
+	}
+	return schedules
+}
 
-	_, names, scores := data.Static.Db.GetUsersList(data.ChatId)
+// removes matches of the entries which schedules are not active at the moment
+func removeInactiveWords(foundWords []matching.WordMatch, schedules map[string]schedule.Schedule, now time.Time) (activeWords []matching.WordMatch) {
+	for _, foundWord := range foundWords {
+		if wordSchedule, ok := schedules[foundWord.Word]; !ok || wordSchedule.IsActive(now) {
+			activeWords = append(activeWords, foundWord)
+		}
+	}
+	return
+}
 
-	for idx, name := range names {
-		score := scores[idx]
-		buffer.WriteString(fmt.Sprintf("\n%s - %d", name, score))
+// unix time of the first temporary word to expire, 0 if there are none
+func getFirstExpiration(words []database.ProhibitedWord) (expiresAt int64) {
+	for _, word := range words {
+		if word.ExpiresAt != 0 && (expiresAt == 0 || word.ExpiresAt < expiresAt) {
+			expiresAt = word.ExpiresAt
+		}
 	}
+	return
+}
 
-	data.Static.Chat.SendMessage(data.ChatId, buffer.String())
+func getProhibitedWords(staticData *processing.StaticProccessStructs, chatId int64) *processing.ChatWords {
+	// the words are loaded again when a temporary word expires
+	if cachedWords, ok := staticData.CachedWords[chatId]; ok && (cachedWords.ExpiresAt == 0 || time.Now().Unix() < cachedWords.ExpiresAt) {
+		return cachedWords
+	} else {
+		normalizer := getChatNormalizer(staticData, chatId)
+		words := removeDisabledCategoryWords(staticData.Db.GetProhibitedWordsData(chatId), staticData.Db.GetCategories(chatId))
+		cachedWords := &processing.ChatWords{
+			Normalizer:       normalizer,
+			AllowedWords:     makeAllowedWordsSet(staticData.Db.GetAllowedWords(chatId), normalizer),
+			Schedules:        makeWordSchedules(words),
+			Location:         getChatLocation(staticData, chatId),
+			ExpiresAt:        getFirstExpiration(words),
+			WordCategories:   makeWordCategories(words),
+			ExcludedEntities: getExcludedEntities(staticData, chatId),
+		}
+		textWords := separateSpecialEntries(words, cachedWords)
+		cachedWords.Scanner = makeScanner(
+			textWords,
+			getChatBoolSetting(staticData, chatId, stemmingSetting, false),
+			getChatBoolSetting(staticData, chatId, transliterationSetting, false),
+			normalizer,
+		)
+		staticData.CachedWords[chatId] = cachedWords
+		return cachedWords
+	}
 }
 
-func amnestyLastWords(data *processing.ProcessData) {
-	if !isSenderAnAdmin(data) {
-		data.Static.Chat.SendMessage(data.ChatId, data.Static.Trans("no_authority"))
-		return
+// emoji are matched by whole grapheme clusters of the raw text
+func findEmoji(text string, prohibitedEmoji map[string]string) (foundEmoji []matching.WordMatch) {
+	for _, emoji := range matching.SplitEmoji(text) {
+		if word, ok := prohibitedEmoji[matching.NormalizeEmoji(emoji)]; ok {
+			foundEmoji = append(foundEmoji, matching.WordMatch{Word: word, Surface: emoji})
+		}
 	}
+	return
+}
 
-	count, err := strconv.Atoi(data.Message)
-	if err != nil || count < 1 {
-		data.Static.Chat.SendMessage(data.ChatId, data.Static.Trans("wrong_count"))
-		return
+func getEntityText(text string, entity tgbotapi.MessageEntity) string {
+	utf16Text := utf16.Encode([]rune(text))
+	if entity.Offset < 0 || entity.Offset+entity.Length > len(utf16Text) {
+		return ""
 	}
+	return string(utf16.Decode(utf16Text[entity.Offset : entity.Offset+entity.Length]))
+}
 
-	words, userId := data.Static.Db.RevokeLastUsedWords(data.ChatId, count, data.UserId)
+func getEntityLinks(text string, entities []tgbotapi.MessageEntity) (links []string) {
+	for _, entity := range entities {
+		switch entity.Type {
+		case "url":
+			links = append(links, getEntityText(text, entity))
+		case "text_link":
+			links = append(links, entity.URL)
+		}
+	}
+	return
+}
 
-	if len(words) <= 0 && userId == -1 {
-		data.Static.Chat.SendMessage(data.ChatId, data.Static.Trans("no_words_amnestied"))
-		return
+func getMessageLinks(message *tgbotapi.Message, extras *telegramChat.MessageExtras) (links []string) {
+	if message.Entities != nil {
+		links = append(links, getEntityLinks(message.Text, *message.Entities)...)
 	}
+	return append(links, getEntityLinks(message.Caption, extras.CaptionEntities)...)
+}
 
-	data.Static.Chat.SendMessage(data.ChatId,
-		fmt.Sprintf(data.Static.Trans("amnestied_words_header"),
-			data.Static.Db.GetUserName(data.ChatId, userId),
-			strings.Join(words, ", "),
-		),
-	)
+// every link is matched by at most one prohibited domain
+func findDomains(links []string, prohibitedDomains []string) (foundDomains []matching.WordMatch) {
+	for _, link := range links {
+		domain := matching.GetLinkDomain(link)
+		if len(domain) == 0 {
+			continue
+		}
+
+		for _, prohibitedDomain := range prohibitedDomains {
+			if matching.IsSubdomain(domain, prohibitedDomain) {
+				foundDomains = append(foundDomains, matching.WordMatch{Word: prohibitedDomain, Surface: domain})
+				break
+			}
+		}
+	}
+	return
 }
 
-func makeUserCommandProcessors() ProcessorFuncMap {
-	return map[string]ProcessorFunc{
-		"add_word":    addWordCommand,
-		"remove_word": removeWordCommand,
-		"words":       listOfWordsCommand,
-		"score":       playerScoresCommand,
-		"amnesty":     amnestyLastWords,
+// a sticker is matched by itself first and then by its set
+func findSticker(sticker *processing.StickerData, words *processing.ChatWords) (foundStickers []matching.WordMatch) {
+	if sticker == nil {
+		return
+	}
+
+	if words.Stickers[sticker.Id] {
+		foundStickers = append(foundStickers, matching.WordMatch{Word: sticker.Id, Surface: sticker.Emoji})
+	} else if len(sticker.SetName) > 0 && words.StickerSets[sticker.SetName] {
+		foundStickers = append(foundStickers, matching.WordMatch{Word: sticker.SetName, Surface: sticker.SetName})
 	}
+	return
 }
 
-func processCommandByProcessors(data *processing.ProcessData, processorsMap ProcessorFuncMap) bool {
-	processor, ok := processorsMap[data.Command]
-	if ok {
-		processor(data)
+func getStickerData(message *tgbotapi.Message, extras *telegramChat.MessageExtras) *processing.StickerData {
+	if message == nil || message.Sticker == nil {
+		return nil
 	}
 
-	return ok
+	// older API versions don't have unique ids
+	id := message.Sticker.FileID
+	if extras != nil && extras.Sticker != nil && len(extras.Sticker.FileUniqueId) > 0 {
+		id = extras.Sticker.FileUniqueId
+	}
+
+	return &processing.StickerData{
+		Id:      id,
+		SetName: message.Sticker.SetName,
+		Emoji:   message.Sticker.Emoji,
+	}
 }
 
-func processCommand(data *processing.ProcessData, processors *Processors) {
-	processed := processCommandByProcessors(data, processors.Main)
-	if processed {
-		return
+// shows the form used in the message when it differs from the prohibited entry
+// fuzzy matches are marked with "≈"
+func formatWordMatch(match matching.WordMatch) string {
+	if match.Distance > 0 {
+		return fmt.Sprintf("%s ≈ %s", match.Surface, match.Word)
+	} else if strings.EqualFold(match.Word, match.Surface) {
+		return match.Word
+	} else {
+		return fmt.Sprintf("%s → %s", match.Surface, match.Word)
 	}
+}
 
-	// if we here it means that no command was processed
-	data.Static.Chat.SendMessage(data.ChatId, data.Static.Trans("warn_unknown_command"))
+// groups the same matches together and shows the weighted fine for each of them
+func formatWeightedMatches(matches []matching.WordMatch, weights []int) (formattedMatches []string, totalFine int) {
+	forms := []string{}
+	counts := map[string]int{}
+	fines := map[string]int{}
+
+	for idx, match := range matches {
+		form := formatWordMatch(match)
+		if _, ok := counts[form]; !ok {
+			forms = append(forms, form)
+		}
+		counts[form]++
+		fines[form] += weights[idx]
+		totalFine += weights[idx]
+	}
+
+	for _, form := range forms {
+		if counts[form] > 1 {
+			formattedMatches = append(formattedMatches, fmt.Sprintf("%s ×%d: %d", form, counts[form], fines[form]))
+		} else {
+			formattedMatches = append(formattedMatches, fmt.Sprintf("%s: %d", form, fines[form]))
+		}
+	}
+
+	return
 }
 
-func getUserName(update *tgbotapi.Update) string {
-	user := update.Message.From
-	if user != nil {
-		if len(user.UserName) > 0 {
-			return user.UserName
+// replaces text of the excluded entities with spaces, entity offsets are in UTF-16 code units
+func removeExcludedEntities(text string, entities []tgbotapi.MessageEntity, excludedEntities map[string]bool) string {
+	isExcluded := func(utf16Offset int) bool {
+		for _, entity := range entities {
+			if excludedEntities[entity.Type] && utf16Offset >= entity.Offset && utf16Offset < entity.Offset+entity.Length {
+				return true
+			}
+		}
+		return false
+	}
+
+	var buffer bytes.Buffer
+
+	utf16Offset := 0
+	for _, r := range text {
+		if isExcluded(utf16Offset) {
+			buffer.WriteRune(' ')
 		} else {
-			return user.FirstName
+			buffer.WriteRune(r)
+		}
+
+		if r >= 0x10000 {
+			utf16Offset += 2
+		} else {
+			utf16Offset++
 		}
-	} else {
-		return "unknown"
 	}
+
+	return buffer.String()
 }
 
-func findWords(text string, words []string) (foundWords []string) {
-	removePunctuation := func(r rune) rune {
-		if strings.ContainsRune(".,:;\"'!@#$%^&*()_+=/\\<>[]{}~", r) {
-			return -1
+// all the texts of a message that can contain prohibited words
+func getMessageTexts(message *tgbotapi.Message, extras *telegramChat.MessageExtras, excludedEntities map[string]bool) (texts []string) {
+	if len(message.Text) > 0 {
+		if message.Entities != nil {
+			texts = append(texts, removeExcludedEntities(message.Text, *message.Entities, excludedEntities))
 		} else {
-			return r
+			texts = append(texts, message.Text)
 		}
 	}
 
-	processingText := text
-	processingText = strings.Map(removePunctuation, processingText)
-	textWords := strings.Fields(processingText)
+	// photos, videos, documents, audio and voice messages
+	if len(message.Caption) > 0 {
+		texts = append(texts, removeExcludedEntities(message.Caption, extras.CaptionEntities, excludedEntities))
+	}
 
-	for _, knownWord := range words {
-		for _, textWord := range textWords {
-			if strings.EqualFold(knownWord, textWord) {
-				foundWords = append(foundWords, knownWord)
-			}
+	if extras.Poll != nil {
+		texts = append(texts, extras.Poll.Question)
+		for _, option := range extras.Poll.Options {
+			texts = append(texts, option.Text)
 		}
 	}
 
 	return
 }
 
-func getProhibitedWords(staticData *processing.StaticProccessStructs, chatId int64) []string {
-	if cachedWords, ok := staticData.CachedWords[chatId]; ok {
-		return cachedWords
-	} else {
-		cachedWords := staticData.Db.GetProhibitedWords(chatId)
-		staticData.CachedWords[chatId] = cachedWords
-		return cachedWords
+// prohibited entries of the matches, an entry is repeated for each of its matches
+func getMatchedWords(matches []matching.WordMatch) (words []string) {
+	for _, match := range matches {
+		words = append(words, match.Word)
+	}
+	return
+}
+
+// removes matches that have already been fined, every fined word removes only one match
+func removeFinedWords(foundWords []matching.WordMatch, finedWords []string) (newWords []matching.WordMatch) {
+	finedCounts := map[string]int{}
+	for _, word := range finedWords {
+		finedCounts[word]++
+	}
+
+	for _, foundWord := range foundWords {
+		if finedCounts[foundWord.Word] > 0 {
+			finedCounts[foundWord.Word]--
+		} else {
+			newWords = append(newWords, foundWord)
+		}
+	}
+	return
+}
+
+// length of the message beginning that is kept with the fined words
+const messageSnippetLength = 100
+
+func makeMessageSnippet(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) > messageSnippetLength {
+		return string(runes[:messageSnippetLength]) + "…"
 	}
+	return text
 }
 
 func processPlainMessage(data *processing.ProcessData) {
-	// ToDo: cache uppercase words
 	words := getProhibitedWords(data.Static, data.ChatId)
 
-	usedProhibitedWords := findWords(data.Message, words)
+	usedProhibitedWords := removeAllowedWords(
+		findWords(data.Message, words.Scanner, words.Normalizer),
+		words.AllowedWords,
+		words.Normalizer,
+	)
+	usedProhibitedWords = append(usedProhibitedWords, findEmoji(data.Message, words.Emoji)...)
+	usedProhibitedWords = append(usedProhibitedWords, findSticker(data.Sticker, words)...)
+	usedProhibitedWords = append(usedProhibitedWords, findDomains(data.Links, words.Domains)...)
+
+	if len(words.Schedules) > 0 {
+		usedProhibitedWords = removeInactiveWords(usedProhibitedWords, words.Schedules, time.Now().In(words.Location))
+	}
+
+	// only words that weren't in the previous version of the message are fined
+	if data.IsEdited && len(usedProhibitedWords) > 0 {
+		usedProhibitedWords = removeFinedWords(usedProhibitedWords, data.Static.Db.GetMessageWords(data.ChatId, data.MessageId))
+	}
+
+	if len(usedProhibitedWords) > 0 {
+		usedProhibitedWords = removeExemptedWords(usedProhibitedWords, data.Static.Db.GetUserExemptions(data.ChatId, data.UserId), words.WordCategories)
+	}
+
+	if data.IsReportOnly {
+		if len(usedProhibitedWords) > 0 {
+			// edits of the forward are not reported again
+			data.Static.Db.AddMessageWords(data.ChatId, data.MessageId, getMatchedWords(usedProhibitedWords))
+
+			usedForms := []string{}
+			for _, usedWord := range usedProhibitedWords {
+				usedForms = append(usedForms, formatWordMatch(usedWord))
+			}
+
+			data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf("%s: %d (%s)",
+				data.Trans("forward_report_message"),
+				len(usedProhibitedWords),
+				strings.Join(usedForms, ", "),
+			))
+		}
+		return
+	}
 
 	if len(usedProhibitedWords) > 0 {
 		data.Static.Db.UpdateUser(data.ChatId, data.UserId, data.UserName)
+		// the user could have missed the event of returning to the chat
+		data.Static.Db.SetUserAbsent(data.ChatId, data.UserId, false)
 
-		data.Static.Db.AddWordsUsage(data.ChatId, data.UserId, usedProhibitedWords)
+		usedWords := getMatchedWords(usedProhibitedWords)
 
-		data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf("%s: %d (%s)\n%s: %d",
-			data.Static.Trans("fine_message"),
+		data.Static.Db.AddMessageWords(data.ChatId, data.MessageId, usedWords)
+
+		for _, usedWord := range usedProhibitedWords {
+			if usedWord.Distance > 0 {
+				data.Static.Db.AddFuzzyHit(data.ChatId, database.FuzzyHit{
+					UserId:   data.UserId,
+					Word:     usedWord.Word,
+					Surface:  usedWord.Surface,
+					Distance: usedWord.Distance,
+				})
+			}
+		}
+
+		weights := data.Static.Db.AddWordsUsage(data.ChatId, data.UserId, data.MessageId, makeMessageSnippet(data.Message), usedWords)
+
+		usedForms, totalFine := formatWeightedMatches(usedProhibitedWords, weights)
+
+		data.Static.Chat.SendMessage(data.ChatId, fmt.Sprintf("%s: %d (%s)\n%s: %d\n%s: %d",
+			data.Trans("fine_message"),
 			len(usedProhibitedWords),
-			strings.Join(usedProhibitedWords, ", "),
-			data.Static.Trans("total_score_message"),
+			strings.Join(usedForms, ", "),
+			data.Trans("fine_points_message"),
+			totalFine,
+			data.Trans("total_score_message"),
 			data.Static.Db.GetUserScore(data.ChatId, data.UserId),
 		))
 	}
 }
 
-func processUpdate(update *tgbotapi.Update, staticData *processing.StaticProccessStructs, processors *Processors) {
+// removes temporary words which time is over and tells the chats about it
+func processExpiredWords(staticData *processing.StaticProccessStructs) {
+	chatIds := []int64{}
+	chatWords := map[int64][]string{}
+
+	for _, expiredWord := range staticData.Db.RemoveExpiredWords() {
+		if _, ok := chatWords[expiredWord.ChatId]; !ok {
+			chatIds = append(chatIds, expiredWord.ChatId)
+		}
+		chatWords[expiredWord.ChatId] = append(chatWords[expiredWord.ChatId], expiredWord.Word)
+	}
+
+	for _, chatId := range chatIds {
+		delete(staticData.CachedWords, chatId)
+		staticData.Chat.SendMessage(chatId, fmt.Sprintf(getChatTrans(staticData, chatId)("temporary_words_expired"), strings.Join(chatWords[chatId], ", ")))
+	}
+}
+
+// words of messages older than this can't be edited anymore
+const messageEditTime = 48 * time.Hour
+
+// fuzzy hits are kept longer to be seen by /fuzzy_hits in quiet chats
+const fuzzyHitsKeepTime = 30 * 24 * time.Hour
+
+func removeOutdatedRecords(staticData *processing.StaticProccessStructs) {
+	now := time.Now()
+	staticData.Db.RemoveMessageWordsBefore(now.Add(-messageEditTime).Unix())
+	staticData.Db.RemoveFuzzyHitsBefore(now.Add(-fuzzyHitsKeepTime).Unix())
+}
+
+func isForwardedMessage(message *tgbotapi.Message, extras *telegramChat.MessageExtras) bool {
+	return message.ForwardFrom != nil ||
+		message.ForwardFromChat != nil ||
+		message.ForwardDate != 0 ||
+		len(extras.ForwardSenderName) > 0 ||
+		extras.ForwardOrigin != nil
+}
+
+// users who joined or left the chat, from service messages or from chat member updates
+func getMemberChanges(update *telegramChat.Update) (chatId int64, presentUserIds []int64, absentUserIds []int64) {
+	if update.ChatMember != nil {
+		chatId = update.ChatMember.Chat.ID
+		userId := int64(update.ChatMember.NewChatMember.User.ID)
+		if update.ChatMember.NewChatMember.IsPresent() {
+			presentUserIds = append(presentUserIds, userId)
+		} else {
+			absentUserIds = append(absentUserIds, userId)
+		}
+		return
+	}
+
+	message := update.Message
+	if message == nil || message.Chat == nil {
+		return
+	}
+
+	chatId = message.Chat.ID
+	if message.NewChatMembers != nil {
+		for _, user := range *message.NewChatMembers {
+			presentUserIds = append(presentUserIds, int64(user.ID))
+		}
+	}
+	if message.LeftChatMember != nil {
+		absentUserIds = append(absentUserIds, int64(message.LeftChatMember.ID))
+	}
+	return
+}
+
+func processMemberChanges(update *telegramChat.Update, staticData *processing.StaticProccessStructs) {
+	chatId, presentUserIds, absentUserIds := getMemberChanges(update)
+	for _, userId := range presentUserIds {
+		staticData.Db.SetUserAbsent(chatId, userId, false)
+	}
+	for _, userId := range absentUserIds {
+		staticData.Db.SetUserAbsent(chatId, userId, true)
+	}
+}
+
+func processUpdate(update *telegramChat.Update, staticData *processing.StaticProccessStructs, processors *Processors) {
+	processMemberChanges(update, staticData)
+
+	message := update.Message
+	isEdited := false
+	if message == nil {
+		message = update.EditedMessage
+		isEdited = true
+	}
+
+	// chat member updates have no message
+	if message == nil {
+		return
+	}
+
 	data := processing.ProcessData{
 		Static:              staticData,
-		ChatId:              update.Message.Chat.ID,
-		UserId:              int64(update.Message.From.ID),
-		AllMembersAreAdmins: update.Message.Chat.AllMembersAreAdmins || update.Message.Chat.IsPrivate(),
+		Trans:               getChatTrans(staticData, message.Chat.ID),
+		ChatId:              message.Chat.ID,
+		MessageId:           int64(message.MessageID),
+		IsEdited:            isEdited,
+		UserId:              int64(message.From.ID),
+		AllMembersAreAdmins: message.Chat.AllMembersAreAdmins || message.Chat.IsPrivate(),
+		Sticker:             getStickerData(message, update.MessageExtras),
+		ReplyToSticker:      getStickerData(message.ReplyToMessage, update.MessageExtras.ReplyToMessage),
+	}
+
+	if message.ReplyToMessage != nil && message.ReplyToMessage.From != nil {
+		data.ReplyToUserId = int64(message.ReplyToMessage.From.ID)
+		data.ReplyToUserName = getUserName(message.ReplyToMessage)
 	}
 
-	message := update.Message.Text
+	if strings.HasPrefix(message.Text, "/") {
+		// edited commands are not executed again
+		if isEdited {
+			return
+		}
 
-	if strings.HasPrefix(message, "/") {
-		commandLen := strings.Index(message, " ")
+		text := message.Text
+		commandLen := strings.Index(text, " ")
 		if commandLen != -1 {
-			data.Command = strings.Split(message[1:commandLen], "@")[0]
-			data.Message = message[commandLen+1:]
+			data.Command = strings.Split(text[1:commandLen], "@")[0]
+			data.Message = text[commandLen+1:]
 		} else {
-			data.Command = strings.Split(message[1:], "@")[0]
+			data.Command = strings.Split(text[1:], "@")[0]
 		}
 
 		processCommand(&data, processors)
 	} else {
-		if update.Message.ForwardFrom == nil {
-			data.Message = message
-			data.UserName = getUserName(update)
-			processPlainMessage(&data)
+		if isForwardedMessage(message, update.MessageExtras) {
+			switch getForwardPolicy(staticData, data.ChatId) {
+			case forwardPolicyFine:
+				// the one who forwarded the message is fined
+			case forwardPolicyReport:
+				data.IsReportOnly = true
+			default:
+				return
+			}
 		}
+
+		data.Message = strings.Join(getMessageTexts(message, update.MessageExtras, getProhibitedWords(staticData, data.ChatId).ExcludedEntities), "\n")
+		data.Links = getMessageLinks(message, update.MessageExtras)
+		data.UserName = getUserName(message)
+		processPlainMessage(&data)
 	}
 }
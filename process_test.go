@@ -1,34 +1,815 @@
 package main
 
 import (
+	"fmt"
+	"github.com/gameraccoon/telegram-prohibited-words-bot/database"
+	"github.com/gameraccoon/telegram-prohibited-words-bot/matching"
+	"github.com/gameraccoon/telegram-prohibited-words-bot/processing"
+	"github.com/gameraccoon/telegram-prohibited-words-bot/telegramChat"
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/nicksnyder/go-i18n/i18n"
 	"github.com/stretchr/testify/require"
+	"strings"
 	"testing"
+	"time"
 )
 
+func makeTestScanner(t *testing.T, patternType matching.PatternType, words []string) *matching.Scanner {
+	matchers := []matching.Matcher{}
+	for _, word := range words {
+		matcher, err := matching.MakeMatcher(patternType, word, matching.Normalizer{})
+		require.NoError(t, err)
+		matchers = append(matchers, matcher)
+	}
+	return matching.MakeScanner(matchers)
+}
+
 func TestWordsCountCalculation(t *testing.T) {
 	assert := require.New(t)
 
 	{
 		testText := "Tested tests test testing"
-		words := []string{"asd"}
-		assert.Equal(0, len(findWords(testText, words)))
+		words := makeTestScanner(t, matching.ExactPattern, []string{"asd"})
+		assert.Equal(0, len(findWords(testText, words, matching.Normalizer{})))
+	}
+
+	{
+		testText := "Tested tests test testing"
+		words := makeTestScanner(t, matching.ExactPattern, []string{"test"})
+		assert.Equal(1, len(findWords(testText, words, matching.Normalizer{})))
+	}
+
+	{
+		testText := "Tested tests test testing"
+		words := makeTestScanner(t, matching.ExactPattern, []string{"Test"})
+		assert.Equal(1, len(findWords(testText, words, matching.Normalizer{})))
 	}
 
 	{
 		testText := "Tested tests test testing"
-		words := []string{"test"}
-		assert.Equal(1, len(findWords(testText, words)))
+		words := makeTestScanner(t, matching.ExactPattern, []string{"Test", "Testing"})
+		assert.Equal(2, len(findWords(testText, words, matching.Normalizer{})))
 	}
+}
+
+func TestPatternWordsCalculation(t *testing.T) {
+	assert := require.New(t)
 
 	{
 		testText := "Tested tests test testing"
-		words := []string{"Test"}
-		assert.Equal(1, len(findWords(testText, words)))
+		words := makeTestScanner(t, matching.GlobPattern, []string{"test*"})
+		assert.Equal(4, len(findWords(testText, words, matching.Normalizer{})))
 	}
 
 	{
 		testText := "Tested tests test testing"
-		words := []string{"Test", "Testing"}
-		assert.Equal(2, len(findWords(testText, words)))
+		words := makeTestScanner(t, matching.GlobPattern, []string{"test?"})
+		assert.Equal(1, len(findWords(testText, words, matching.Normalizer{})))
+	}
+
+	{
+		testText := "fuuuck fuck fck"
+		words := makeTestScanner(t, matching.RegexPattern, []string{"f+u+c+k"})
+		assert.Equal([]matching.WordMatch{
+			{Word: "f+u+c+k", Surface: "fuuuck"},
+			{Word: "f+u+c+k", Surface: "fuck"},
+		}, findWords(testText, words, matching.Normalizer{}))
+	}
+}
+
+func TestStemmingWordsCalculation(t *testing.T) {
+	assert := require.New(t)
+
+	words := makeScanner([]database.ProhibitedWord{
+		{Word: "работа", Stemming: database.WordStemmingDefault},
+		{Word: "дело", Stemming: database.WordStemmingDisabled},
+		{Word: "слово", Stemming: database.WordStemmingEnabled},
+	}, true, false, matching.Normalizer{})
+
+	{
+		testText := "Опять работой заняты, все дела да слова"
+		assert.Equal([]matching.WordMatch{
+			{Word: "работа", Surface: "работой"},
+			{Word: "слово", Surface: "слова"},
+		}, findWords(testText, words, matching.Normalizer{}))
+	}
+
+	words = makeScanner([]database.ProhibitedWord{
+		{Word: "работа", Stemming: database.WordStemmingDefault},
+		{Word: "слово", Stemming: database.WordStemmingEnabled},
+	}, false, false, matching.Normalizer{})
+
+	{
+		testText := "Опять работой заняты, все дела да слова"
+		assert.Equal([]matching.WordMatch{
+			{Word: "слово", Surface: "слова"},
+		}, findWords(testText, words, matching.Normalizer{}))
+	}
+}
+
+func TestNormalizedWordsCalculation(t *testing.T) {
+	assert := require.New(t)
+
+	normalizer := matching.Normalizer{
+		FoldConfusables: true,
+		StripInvisible:  true,
+		CollapseRepeats: true,
+		MapLeet:         true,
+	}
+
+	words := makeScanner([]database.ProhibitedWord{
+		{Word: "привет", Stemming: database.WordStemmingDefault},
+		{Word: "hello", Stemming: database.WordStemmingDefault},
+		{Word: "бля*", PatternType: int(matching.GlobPattern), Stemming: database.WordStemmingDefault},
+	}, false, false, normalizer)
+
+	testText := "пpивeт, h3ll0 и при\u200bвет, бляяяя"
+	assert.Equal([]matching.WordMatch{
+		{Word: "привет", Surface: "пpивeт"},
+		{Word: "hello", Surface: "h3ll0"},
+		{Word: "привет", Surface: "привет"},
+		{Word: "бля*", Surface: "бляяяя"},
+	}, findWords(testText, words, normalizer))
+
+	// only the pattern matches without normalization
+	assert.Equal(1, len(findWords(testText, words, matching.Normalizer{})))
+}
+
+func TestPhrasesCalculation(t *testing.T) {
+	assert := require.New(t)
+
+	words := makeScanner([]database.ProhibitedWord{
+		{Word: "как бы", Stemming: database.WordStemmingDefault},
+		{Word: "в общем-то", Stemming: database.WordStemmingDefault},
+		{Word: "хорошая работа", Stemming: database.WordStemmingEnabled},
+		{Word: "бы", Stemming: database.WordStemmingDefault},
+	}, false, false, matching.Normalizer{})
+
+	{
+		testText := "Как, бы сказать... в общем-то, как-то бы так"
+		assert.Equal([]matching.WordMatch{
+			{Word: "как бы", Surface: "Как бы"},
+			{Word: "в общем-то", Surface: "в общем-то"},
+			{Word: "бы", Surface: "бы"},
+		}, findWords(testText, words, matching.Normalizer{}))
+	}
+
+	{
+		testText := "За хорошую работу"
+		assert.Equal([]matching.WordMatch{
+			{Word: "хорошая работа", Surface: "хорошую работу"},
+		}, findWords(testText, words, matching.Normalizer{}))
+	}
+}
+
+func makeBenchmarkData(wordsCount int) (words []database.ProhibitedWord, text string) {
+	for i := 0; i < wordsCount; i++ {
+		words = append(words, database.ProhibitedWord{
+			Word:     fmt.Sprintf("слово%d", i),
+			Stemming: database.WordStemmingDefault,
+		})
+	}
+
+	// a long pasted message with a few prohibited words
+	textWords := []string{}
+	for i := 0; i < 2000; i++ {
+		if i%100 == 0 {
+			textWords = append(textWords, fmt.Sprintf("слово%d", i/100))
+		} else {
+			textWords = append(textWords, "обычный", "текст,")
+		}
+	}
+	text = strings.Join(textWords, " ")
+	return
+}
+
+// the way words were searched before the automaton: every word against every token
+func findWordsNaive(text string, matchers []matching.Matcher, normalizer matching.Normalizer) (foundWords []matching.WordMatch) {
+	tokens := makeTokens(text, normalizer)
+	for _, matcher := range matchers {
+		for position := range tokens {
+			if matcher.Match(tokens, position) > 0 {
+				foundWords = append(foundWords, matching.WordMatch{Word: matcher.GetWord(), Surface: tokens[position].Text})
+			}
+		}
+	}
+	return
+}
+
+func TestNaiveAndAutomatonResultsAreEqual(t *testing.T) {
+	assert := require.New(t)
+
+	words, text := makeBenchmarkData(1000)
+	normalizer := matching.Normalizer{FoldConfusables: true}
+
+	naiveResult := findWordsNaive(text, makeMatchers(words, false, false, normalizer), normalizer)
+	automatonResult := findWords(text, makeScanner(words, false, false, normalizer), normalizer)
+
+	assert.Equal(20, len(automatonResult))
+	assert.ElementsMatch(naiveResult, automatonResult)
+}
+
+func benchmarkFindWordsNaive(b *testing.B, wordsCount int) {
+	words, text := makeBenchmarkData(wordsCount)
+	normalizer := matching.Normalizer{FoldConfusables: true}
+	matchers := makeMatchers(words, false, false, normalizer)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		findWordsNaive(text, matchers, normalizer)
+	}
+}
+
+func benchmarkFindWordsAutomaton(b *testing.B, wordsCount int) {
+	words, text := makeBenchmarkData(wordsCount)
+	normalizer := matching.Normalizer{FoldConfusables: true}
+	scanner := makeScanner(words, false, false, normalizer)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		findWords(text, scanner, normalizer)
+	}
+}
+
+func BenchmarkFindWordsNaive100(b *testing.B) {
+	benchmarkFindWordsNaive(b, 100)
+}
+
+func BenchmarkFindWordsAutomaton100(b *testing.B) {
+	benchmarkFindWordsAutomaton(b, 100)
+}
+
+func BenchmarkFindWordsNaive1000(b *testing.B) {
+	benchmarkFindWordsNaive(b, 1000)
+}
+
+func BenchmarkFindWordsAutomaton1000(b *testing.B) {
+	benchmarkFindWordsAutomaton(b, 1000)
+}
+
+func BenchmarkFindWordsNaive5000(b *testing.B) {
+	benchmarkFindWordsNaive(b, 5000)
+}
+
+func BenchmarkFindWordsAutomaton5000(b *testing.B) {
+	benchmarkFindWordsAutomaton(b, 5000)
+}
+
+func BenchmarkBuildScanner1000(b *testing.B) {
+	words, _ := makeBenchmarkData(1000)
+	normalizer := matching.Normalizer{FoldConfusables: true}
+
+	for i := 0; i < b.N; i++ {
+		makeScanner(words, false, false, normalizer)
+	}
+}
+
+func TestAllowedWords(t *testing.T) {
+	assert := require.New(t)
+
+	normalizer := matching.Normalizer{FoldConfusables: true}
+
+	words := makeScanner([]database.ProhibitedWord{
+		{Word: "хле*", PatternType: int(matching.GlobPattern), Stemming: database.WordStemmingDefault},
+		{Word: "как бы", Stemming: database.WordStemmingDefault},
+	}, false, false, normalizer)
+
+	allowedWords := makeAllowedWordsSet([]string{"Хлеб", "как  бы"}, normalizer)
+
+	testText := "Хлеб, хлебушек и xлеб, как бы"
+	assert.Equal([]matching.WordMatch{
+		{Word: "хле*", Surface: "хлебушек"},
+	}, removeAllowedWords(findWords(testText, words, normalizer), allowedWords, normalizer))
+}
+
+func TestFormatWeightedMatches(t *testing.T) {
+	assert := require.New(t)
+
+	matches := []matching.WordMatch{
+		{Word: "бля", Surface: "бля"},
+		{Word: "работа", Surface: "работой"},
+		{Word: "бля", Surface: "Бля"},
+	}
+
+	formattedMatches, totalFine := formatWeightedMatches(matches, []int{5, 1, 5})
+	assert.Equal([]string{"бля ×2: 10", "работой → работа: 1"}, formattedMatches)
+	assert.Equal(11, totalFine)
+}
+
+func TestWordCategories(t *testing.T) {
+	assert := require.New(t)
+
+	{
+		category, words, ok := parseCategoryPrefix("politics: выборы, партия")
+		assert.True(ok)
+		assert.Equal("politics", category)
+		assert.Equal(" выборы, партия", words)
+	}
+	{
+		category, words, ok := parseCategoryPrefix("Politics:re:выбор.*")
+		assert.True(ok)
+		assert.Equal("politics", category)
+		assert.Equal("re:выбор.*", words)
+	}
+	{
+		category, words, ok := parseCategoryPrefix("re:выбор.*")
+		assert.True(ok)
+		assert.Equal("", category)
+		assert.Equal("re:выбор.*", words)
+	}
+	{
+		category, words, ok := parseCategoryPrefix("слово, glob:партия*")
+		assert.True(ok)
+		assert.Equal("", category)
+		assert.Equal("слово, glob:партия*", words)
+	}
+	{
+		category, words, ok := parseCategoryPrefix("выборы, партия")
+		assert.True(ok)
+		assert.Equal("", category)
+		assert.Equal("выборы, партия", words)
+	}
+
+	{
+		category, words, ok := parseCategoryPrefix("12:00")
+		assert.True(ok)
+		assert.Equal("", category)
+		assert.Equal("12:00", words)
+	}
+
+	{
+		category, _, ok := parseCategoryPrefix("All: word")
+		assert.False(ok)
+		assert.Equal("all", category)
+	}
+
+	words := removeDisabledCategoryWords([]database.ProhibitedWord{
+		{Word: "выборы", CategoryId: 1},
+		{Word: "работа", CategoryId: 2},
+		{Word: "слово", CategoryId: -1},
+	}, []database.WordCategory{
+		{Id: 1, Name: "politics", IsEnabled: true},
+		{Id: 2, Name: "work", IsEnabled: false},
+	})
+
+	assert.Equal(2, len(words))
+	assert.Equal("выборы", words[0].Word)
+	assert.Equal("слово", words[1].Word)
+}
+
+func TestMessageTexts(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal([]string{"text"}, getMessageTexts(&tgbotapi.Message{Text: "text"}, &telegramChat.MessageExtras{}, map[string]bool{}))
+	assert.Equal([]string{"caption"}, getMessageTexts(&tgbotapi.Message{Caption: "caption"}, &telegramChat.MessageExtras{}, map[string]bool{}))
+	assert.Equal(0, len(getMessageTexts(&tgbotapi.Message{}, &telegramChat.MessageExtras{}, map[string]bool{})))
+
+	poll := &telegramChat.Poll{
+		Question: "question",
+		Options:  []telegramChat.PollOption{{Text: "option1"}, {Text: "option2"}},
+	}
+	assert.Equal([]string{"question", "option1", "option2"}, getMessageTexts(&tgbotapi.Message{}, &telegramChat.MessageExtras{Poll: poll}, map[string]bool{}))
+}
+
+func TestEditedMessageWords(t *testing.T) {
+	assert := require.New(t)
+
+	words := makeTestScanner(t, matching.ExactPattern, []string{"хлеб", "соль"})
+
+	// the first version of the message was fined for "хлеб" once
+	finedWords := []string{"хлеб"}
+
+	assert.Equal([]matching.WordMatch{
+		{Word: "хлеб", Surface: "хлеб"},
+		{Word: "соль", Surface: "соль"},
+	}, removeFinedWords(findWords("хлеб, хлеб и соль", words, matching.Normalizer{}), finedWords))
+
+	assert.Equal(0, len(removeFinedWords(findWords("только хлеб", words, matching.Normalizer{}), finedWords)))
+	assert.Equal(0, len(removeFinedWords(findWords("ничего", words, matching.Normalizer{}), finedWords)))
+}
+
+func TestForwardedMessages(t *testing.T) {
+	assert := require.New(t)
+
+	noExtras := &telegramChat.MessageExtras{}
+
+	assert.False(isForwardedMessage(&tgbotapi.Message{}, noExtras))
+	assert.True(isForwardedMessage(&tgbotapi.Message{ForwardFrom: &tgbotapi.User{}, ForwardDate: 1}, noExtras))
+	assert.True(isForwardedMessage(&tgbotapi.Message{ForwardFromChat: &tgbotapi.Chat{}, ForwardDate: 1}, noExtras))
+	// hidden users have only the date and the name
+	assert.True(isForwardedMessage(&tgbotapi.Message{}, &telegramChat.MessageExtras{ForwardSenderName: "name"}))
+	assert.True(isForwardedMessage(&tgbotapi.Message{}, &telegramChat.MessageExtras{ForwardOrigin: &telegramChat.MessageOrigin{Type: "hidden_user"}}))
+}
+
+func TestExcludedEntities(t *testing.T) {
+	assert := require.New(t)
+
+	excludedEntities := map[string]bool{"url": true, "code": true}
+
+	// offsets are in UTF-16 code units, the emoji takes two of them
+	text := "😀 хлеб example.com/хлеб `хлеб` #хлеб"
+	entities := []tgbotapi.MessageEntity{
+		{Type: "url", Offset: 8, Length: 16},
+		{Type: "code", Offset: 25, Length: 6},
+		{Type: "hashtag", Offset: 32, Length: 5},
+	}
+
+	assert.Equal("😀 хлеб"+strings.Repeat(" ", 25)+"#хлеб", removeExcludedEntities(text, entities, excludedEntities))
+
+	words := makeTestScanner(t, matching.ExactPattern, []string{"хлеб"})
+	assert.Equal(2, len(findWords(removeExcludedEntities(text, entities, excludedEntities), words, matching.Normalizer{})))
+
+	message := &tgbotapi.Message{Text: text, Entities: &entities, Caption: "`хлеб`"}
+	extras := &telegramChat.MessageExtras{CaptionEntities: []tgbotapi.MessageEntity{{Type: "code", Offset: 0, Length: 6}}}
+	assert.Equal([]string{removeExcludedEntities(text, entities, excludedEntities), "      "}, getMessageTexts(message, extras, excludedEntities))
+}
+
+func TestTransliteratedWordsCalculation(t *testing.T) {
+	assert := require.New(t)
+
+	normalizer := matching.Normalizer{FoldConfusables: true}
+
+	words := makeScanner([]database.ProhibitedWord{
+		{Word: "привет", Stemming: database.WordStemmingDefault},
+		{Word: "blin", Stemming: database.WordStemmingDefault},
+		{Word: "как бы", Stemming: database.WordStemmingDefault},
+		{Word: "прив*", PatternType: int(matching.GlobPattern), Stemming: database.WordStemmingDefault},
+	}, false, true, normalizer)
+
+	testText := "Privet! Блин, kak by privet"
+	assert.Equal([]matching.WordMatch{
+		{Word: "привет", Surface: "Privet"},
+		{Word: "blin", Surface: "Блин"},
+		{Word: "как бы", Surface: "kak by"},
+		{Word: "привет", Surface: "privet"},
+	}, findWords(testText, words, normalizer))
+}
+
+func TestTokenization(t *testing.T) {
+	assert := require.New(t)
+
+	testCases := []struct {
+		text     string
+		expected []string
+	}{
+		{"просто слова", []string{"просто", "слова"}},
+		{"  пробелы\tи\nпереносы  ", []string{"пробелы", "и", "переносы"}},
+		{"«ёлки»", []string{"ёлки"}},
+		{"„лапки“ и “quotes”", []string{"лапки", "и", "quotes"}},
+		{"слово—слово – слово", []string{"слово", "слово", "слово"}},
+		{"ну…", []string{"ну"}},
+		{"блин😀😀", []string{"блин"}},
+		{"😀блин", []string{"блин"}},
+		{"кто-то", []string{"кто", "то"}},
+		{"кто‐то", []string{"кто", "то"}},
+		{"don't it’s", []string{"dont", "its"}},
+		{"'цитата'", []string{"цитата"}},
+		{"#хэштег @user", []string{"хэштег", "user"}},
+		{"snake_case", []string{"snake", "case"}},
+		{"123 h3ll0", []string{"123", "h3ll0"}},
+		{"¿qué?", []string{"qué"}},
+		{"мой", []string{"мой"}},
+		{"...", nil},
+		{"", nil},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(testCase.expected, splitToTokens(testCase.text), testCase.text)
+	}
+}
+
+func TestUnicodeWordsCalculation(t *testing.T) {
+	assert := require.New(t)
+
+	words := makeScanner([]database.ProhibitedWord{
+		{Word: "ёлки", Stemming: database.WordStemmingDefault},
+		{Word: "блин", Stemming: database.WordStemmingDefault},
+		{Word: "кто-то", Stemming: database.WordStemmingDefault},
+	}, false, false, matching.Normalizer{})
+
+	testText := "«Елки» и «ёлки», блин😀, кто-то — кто то"
+	assert.Equal([]matching.WordMatch{
+		{Word: "ёлки", Surface: "Елки"},
+		{Word: "ёлки", Surface: "ёлки"},
+		{Word: "блин", Surface: "блин"},
+		{Word: "кто-то", Surface: "кто-то"},
+		{Word: "кто-то", Surface: "кто то"},
+	}, findWords(testText, words, matching.Normalizer{}))
+}
+
+func TestFuzzyWordsCalculation(t *testing.T) {
+	assert := require.New(t)
+
+	words := makeScanner([]database.ProhibitedWord{
+		{Word: "fuck", Stemming: database.WordStemmingDefault, FuzzyDistance: 2},
+		{Word: "работа", Stemming: database.WordStemmingEnabled, FuzzyDistance: 2},
+		{Word: "бля", Stemming: database.WordStemmingDefault, FuzzyDistance: 2},
+		{Word: "слово", Stemming: database.WordStemmingDefault},
+	}, false, false, matching.Normalizer{})
+
+	testText := "fcuk, fuck, работой, робота, бл, слова"
+	foundWords := findWords(testText, words, matching.Normalizer{})
+	assert.Equal([]matching.WordMatch{
+		{Word: "fuck", Surface: "fcuk", Distance: 1},
+		{Word: "fuck", Surface: "fuck"},
+		{Word: "работа", Surface: "работой"},
+		{Word: "работа", Surface: "робота", Distance: 1},
+	}, foundWords)
+
+	assert.Equal("fcuk ≈ fuck", formatWordMatch(foundWords[0]))
+	assert.Equal("fuck", formatWordMatch(foundWords[1]))
+}
+
+func TestEmojiAndStickers(t *testing.T) {
+	assert := require.New(t)
+
+	words := &processing.ChatWords{}
+	textWords := separateSpecialEntries([]database.ProhibitedWord{
+		{Word: "🤡"},
+		{Word: "👍"},
+		{Word: "клоун"},
+		{Word: "AgADBAADx", Kind: database.WordKindSticker},
+		{Word: "stickers_by_bot", Kind: database.WordKindStickerSet},
+	}, words)
+
+	assert.Equal([]database.ProhibitedWord{{Word: "клоун"}}, textWords)
+
+	assert.Equal([]matching.WordMatch{
+		{Word: "🤡", Surface: "🤡"},
+		{Word: "👍", Surface: "👍🏽"},
+		{Word: "🤡", Surface: "🤡"},
+	}, findEmoji("ты 🤡👍🏽, клоун🤡 💩", words.Emoji))
+
+	assert.Equal(0, len(findSticker(nil, words)))
+	assert.Equal([]matching.WordMatch{
+		{Word: "AgADBAADx", Surface: "😀"},
+	}, findSticker(&processing.StickerData{Id: "AgADBAADx", SetName: "stickers_by_bot", Emoji: "😀"}, words))
+	assert.Equal([]matching.WordMatch{
+		{Word: "stickers_by_bot", Surface: "stickers_by_bot"},
+	}, findSticker(&processing.StickerData{Id: "AgADBAADy", SetName: "stickers_by_bot", Emoji: "😀"}, words))
+	assert.Equal(0, len(findSticker(&processing.StickerData{Id: "AgADBAADz", SetName: "other_set"}, words)))
+}
+
+func TestProhibitedDomains(t *testing.T) {
+	assert := require.New(t)
+
+	text := "😀 смотри example.com/path и тут"
+	entities := []tgbotapi.MessageEntity{
+		{Type: "url", Offset: 10, Length: 16},
+		{Type: "text_link", Offset: 29, Length: 3, URL: "https://www.xn--e1afmkfd.xn--p1ai/page"},
+	}
+	message := &tgbotapi.Message{Text: text, Entities: &entities, Caption: "sub.other.org"}
+	extras := &telegramChat.MessageExtras{CaptionEntities: []tgbotapi.MessageEntity{{Type: "url", Offset: 0, Length: 13}}}
+
+	links := getMessageLinks(message, extras)
+	assert.Equal([]string{"example.com/path", "https://www.xn--e1afmkfd.xn--p1ai/page", "sub.other.org"}, links)
+
+	words := &processing.ChatWords{}
+	separateSpecialEntries([]database.ProhibitedWord{
+		{Word: "пример.рф", Kind: database.WordKindDomain},
+		{Word: "other.org", Kind: database.WordKindDomain},
+		{Word: "ample.com", Kind: database.WordKindDomain},
+	}, words)
+
+	assert.Equal([]matching.WordMatch{
+		{Word: "пример.рф", Surface: "пример.рф"},
+		{Word: "other.org", Surface: "sub.other.org"},
+	}, findDomains(links, words.Domains))
+}
+
+func TestWordOptionsCalculation(t *testing.T) {
+	assert := require.New(t)
+
+	words := makeScanner([]database.ProhibitedWord{
+		{Word: "IT", Stemming: database.WordStemmingDefault, Options: database.WordOptionCaseSensitive},
+		{Word: "бля", Stemming: database.WordStemmingDefault, Options: database.WordOptionSubstring},
+		{Word: "Big Data", Stemming: database.WordStemmingDefault, Options: database.WordOptionCaseSensitive},
+		{Word: "работа", Stemming: database.WordStemmingEnabled, Options: database.WordOptionSubstring},
+		{Word: "дело", Stemming: database.WordStemmingEnabled, Options: database.WordOptionWholeToken},
+	}, true, true, matching.Normalizer{})
+
+	// whole tokens are matched without word forms and transliterations
+	testText := "it IT рубля Бля big data Big Data работой подработать дела delo Дело"
+	assert.Equal([]matching.WordMatch{
+		{Word: "IT", Surface: "IT"},
+		{Word: "бля", Surface: "рубля"},
+		{Word: "бля", Surface: "Бля"},
+		{Word: "Big Data", Surface: "Big Data"},
+		{Word: "работа", Surface: "подработать"},
+		{Word: "дело", Surface: "Дело"},
+	}, findWords(testText, words, matching.Normalizer{}))
+
+	assert.Equal("'IT' [case]", formatWordForList(database.ProhibitedWord{Word: "IT", Weight: 1, Options: database.WordOptionCaseSensitive}))
+	assert.Equal("'бля' [case] [substring]", formatWordForList(database.ProhibitedWord{Word: "бля", Weight: 1, Options: database.WordOptionCaseSensitive | database.WordOptionSubstring}))
+	assert.Equal("'дело' [whole]", formatWordForList(database.ProhibitedWord{Word: "дело", Weight: 1, Options: database.WordOptionWholeToken}))
+}
+
+func TestScheduledWords(t *testing.T) {
+	assert := require.New(t)
+
+	words := []database.ProhibitedWord{
+		{Word: "работа", Schedule: "sat-sun"},
+		{Word: "политика", Schedule: "22:00-06:00"},
+		{Word: "сломано", Schedule: "someday"},
+		{Word: "всегда"},
+	}
+	schedules := makeWordSchedules(words)
+	assert.Equal(2, len(schedules))
+
+	found := []matching.WordMatch{
+		{Word: "работа", Surface: "работой"},
+		{Word: "политика", Surface: "политика"},
+		{Word: "сломано", Surface: "сломано"},
+		{Word: "всегда", Surface: "всегда"},
+	}
+
+	// Saturday afternoon
+	assert.Equal([]matching.WordMatch{
+		{Word: "работа", Surface: "работой"},
+		{Word: "сломано", Surface: "сломано"},
+		{Word: "всегда", Surface: "всегда"},
+	}, removeInactiveWords(found, schedules, time.Date(2024, time.January, 6, 15, 0, 0, 0, time.UTC)))
+
+	// Monday night
+	assert.Equal([]matching.WordMatch{
+		{Word: "политика", Surface: "политика"},
+		{Word: "сломано", Surface: "сломано"},
+		{Word: "всегда", Surface: "всегда"},
+	}, removeInactiveWords(found, schedules, time.Date(2024, time.January, 8, 23, 0, 0, 0, time.UTC)))
+}
+
+func TestTemporaryWordsParameters(t *testing.T) {
+	assert := require.New(t)
+
+	checkDuration := func(text string, expectedWords string, expectedDuration time.Duration) {
+		words, duration := parseWordsDuration(text)
+		assert.Equal(expectedWords, words, text)
+		assert.Equal(expectedDuration, duration, text)
 	}
+
+	checkDuration("шутка 24h", "шутка", 24*time.Hour)
+	checkDuration("шутка, анекдот 30m", "шутка, анекдот", 30*time.Minute)
+	checkDuration("шутка 2Д", "шутка", 2*24*time.Hour)
+	checkDuration("шутка 1w", "шутка", 7*24*time.Hour)
+	checkDuration("шутка", "шутка", 0)
+	checkDuration("24h", "24h", 0)
+	checkDuration("шутка 0h", "шутка 0h", 0)
+	checkDuration("шутка 24y", "шутка 24y", 0)
+
+	assert.Equal(int64(0), getFirstExpiration([]database.ProhibitedWord{{Word: "слово"}}))
+	assert.Equal(int64(100), getFirstExpiration([]database.ProhibitedWord{
+		{Word: "слово"},
+		{Word: "шутка", ExpiresAt: 200},
+		{Word: "анекдот", ExpiresAt: 100},
+	}))
+}
+
+func TestExemptedWords(t *testing.T) {
+	assert := require.New(t)
+
+	wordCategories := makeWordCategories([]database.ProhibitedWord{
+		{Word: "политика", CategoryId: 1},
+		{Word: "выборы", CategoryId: 1},
+		{Word: "ошибка", CategoryId: -1},
+		{Word: "слово", CategoryId: -1},
+	})
+	assert.Equal(map[string]int64{"политика": 1, "выборы": 1}, wordCategories)
+
+	found := []matching.WordMatch{
+		{Word: "политика", Surface: "политика"},
+		{Word: "ошибка", Surface: "ошибки"},
+		{Word: "выборы", Surface: "выборы"},
+		{Word: "слово", Surface: "слово"},
+	}
+
+	assert.Equal(found, removeExemptedWords(found, nil, wordCategories))
+
+	assert.Equal([]matching.WordMatch{
+		{Word: "слово", Surface: "слово"},
+	}, removeExemptedWords(found, []database.Exemption{
+		{UserId: 1, CategoryId: 1},
+		{UserId: 1, Word: "ошибка", CategoryId: -1},
+	}, wordCategories))
+
+	assert.Equal(0, len(removeExemptedWords(found, []database.Exemption{{UserId: 1, CategoryId: -1}}, wordCategories)))
+}
+
+func TestMessageSnippet(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal("", makeMessageSnippet(""))
+	assert.Equal("одна строка и другая", makeMessageSnippet("одна  строка\nи другая"))
+	assert.Equal(strings.Repeat("ё", messageSnippetLength)+"…", makeMessageSnippet(strings.Repeat("ё", messageSnippetLength+1)))
+	assert.Equal(strings.Repeat("ё", messageSnippetLength), makeMessageSnippet(strings.Repeat("ё", messageSnippetLength)))
+}
+
+func TestTranslations(t *testing.T) {
+	assert := require.New(t)
+
+	i18n.MustLoadTranslationFile("./data/strings/ru-ru.all.json")
+	i18n.MustLoadTranslationFile("./data/strings/en-us.all.json")
+
+	// every language has all the strings
+	assert.ElementsMatch(i18n.LanguageTranslationIDs("ru-ru"), i18n.LanguageTranslationIDs("en-us"))
+
+	language, ok := findLanguage("en")
+	assert.True(ok)
+	assert.Equal("en-us", language)
+
+	language, ok = findLanguage("RU_ru")
+	assert.True(ok)
+	assert.Equal("ru-ru", language)
+
+	_, ok = findLanguage("e")
+	assert.False(ok)
+	_, ok = findLanguage("de")
+	assert.False(ok)
+
+	// every language besides English has all the command arguments
+	for _, language := range i18n.LanguageTags() {
+		if language == "en-us" {
+			continue
+		}
+		for _, argument := range localizedArguments {
+			assert.NotEmpty(argumentAliases[language][argument], language+" "+argument)
+		}
+	}
+
+	assert.True(isArgument("Word", "word"))
+	assert.True(isArgument("Слово", "word"))
+	assert.True(isArgument("все", "all"))
+	assert.False(isArgument("все", "word"))
+}
+
+func TestPageRange(t *testing.T) {
+	assert := require.New(t)
+
+	checkPage := func(itemsCount int, page int, expectedFrom int, expectedTo int, expectedPagesCount int) {
+		from, to, pagesCount := getPageRange(itemsCount, 10, page)
+		assert.Equal([]int{expectedFrom, expectedTo, expectedPagesCount}, []int{from, to, pagesCount})
+	}
+
+	checkPage(0, 1, 0, 0, 1)
+	checkPage(5, 1, 0, 5, 1)
+	checkPage(10, 1, 0, 10, 1)
+	checkPage(25, 1, 0, 10, 3)
+	checkPage(25, 3, 20, 25, 3)
+	checkPage(25, 4, 25, 25, 3)
+
+	assert.Equal("слово ×3, другое ×1", formatWordCounts([]database.WordCount{{Word: "слово", Count: 3}, {Word: "другое", Count: 1}}))
+}
+
+func TestMemberChanges(t *testing.T) {
+	assert := require.New(t)
+
+	chat := &tgbotapi.Chat{ID: 12}
+
+	joined := telegramChat.Update{Update: tgbotapi.Update{Message: &tgbotapi.Message{
+		Chat:           chat,
+		NewChatMembers: &[]tgbotapi.User{{ID: 1}, {ID: 2}},
+	}}}
+	chatId, present, absent := getMemberChanges(&joined)
+	assert.Equal(int64(12), chatId)
+	assert.Equal([]int64{1, 2}, present)
+	assert.Empty(absent)
+
+	left := telegramChat.Update{Update: tgbotapi.Update{Message: &tgbotapi.Message{
+		Chat:           chat,
+		LeftChatMember: &tgbotapi.User{ID: 3},
+	}}}
+	_, present, absent = getMemberChanges(&left)
+	assert.Empty(present)
+	assert.Equal([]int64{3}, absent)
+
+	kicked := telegramChat.Update{ChatMember: &telegramChat.ChatMemberUpdated{
+		Chat:          *chat,
+		OldChatMember: telegramChat.ChatMember{User: tgbotapi.User{ID: 4}, Status: "member"},
+		NewChatMember: telegramChat.ChatMember{User: tgbotapi.User{ID: 4}, Status: "kicked"},
+	}}
+	chatId, present, absent = getMemberChanges(&kicked)
+	assert.Equal(int64(12), chatId)
+	assert.Empty(present)
+	assert.Equal([]int64{4}, absent)
+
+	plainMessage := telegramChat.Update{Update: tgbotapi.Update{Message: &tgbotapi.Message{Chat: chat, Text: "text"}}}
+	_, present, absent = getMemberChanges(&plainMessage)
+	assert.Empty(present)
+	assert.Empty(absent)
+}
+
+func TestScoreParameters(t *testing.T) {
+	assert := require.New(t)
+
+	checkParameters := func(message string, expectedCategory string, expectedIncludeAbsent bool) {
+		categoryName, includeAbsent := parseScoreParameters(message)
+		assert.Equal(expectedCategory, categoryName, message)
+		assert.Equal(expectedIncludeAbsent, includeAbsent, message)
+	}
+
+	checkParameters("", "", false)
+	checkParameters("all", "", true)
+	checkParameters(" Politics ", "politics", false)
+	checkParameters("politics ALL", "politics", true)
+	checkParameters("bad words", "bad words", false)
+	checkParameters("overall", "overall", false)
 }
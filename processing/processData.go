@@ -1,10 +1,36 @@
 package processing
 
+import (
+	"github.com/nicksnyder/go-i18n/i18n"
+)
+
+type StickerData struct {
+	Id      string
+	SetName string
+	Emoji   string
+}
+
 type ProcessData struct {
 	Static  *StaticProccessStructs
+	// translations to the language of the chat
+	Trans   i18n.TranslateFunc
 	Command string // first part of command without slash(/)
 	Message string // parameters of command or plain message
 	ChatId  int64
+	MessageId int64
+	// the message is an edited version of an already processed one
+	IsEdited bool
+	// prohibited words are only reported, without fines
+	IsReportOnly bool
+	// sticker of the message, nil if there is no sticker
+	Sticker *StickerData
+	// sticker of the message that this one replies to
+	ReplyToSticker *StickerData
+	// links of the message, both visible and hidden under text
+	Links []string
+	// author of the message that this one replies to, 0 if it's not a reply
+	ReplyToUserId int64
+	ReplyToUserName string
 	UserId int64
 	UserName string
 	AllMembersAreAdmins bool
@@ -3,7 +3,10 @@ package processing
 import (
 	"github.com/gameraccoon/telegram-prohibited-words-bot/chat"
 	"github.com/gameraccoon/telegram-prohibited-words-bot/database"
+	"github.com/gameraccoon/telegram-prohibited-words-bot/matching"
+	"github.com/gameraccoon/telegram-prohibited-words-bot/schedule"
 	"github.com/nicksnyder/go-i18n/i18n"
+	"time"
 )
 
 type UserState int
@@ -20,10 +23,48 @@ type StaticConfiguration struct {
 	ExtendedLog bool
 }
 
+// prohibited words of a chat prepared for matching
+type ChatWords struct {
+	Scanner    *matching.Scanner
+	Normalizer matching.Normalizer
+	// normalized words and phrases that never produce a fine
+	AllowedWords map[string]bool
+	// normalized emoji to the prohibited entries
+	Emoji map[string]string
+	// prohibited sticker ids and sticker set names
+	Stickers    map[string]bool
+	StickerSets map[string]bool
+	// normalized domains of links
+	Domains []string
+	// schedules of the entries that are not always active
+	Schedules map[string]schedule.Schedule
+	// time zone the schedules are checked in
+	Location *time.Location
+	// unix time when the first temporary word expires, 0 if there are no temporary words
+	ExpiresAt int64
+	// categories of the words that belong to one, used to check exemptions
+	WordCategories map[string]int64
+	// entity types which text is not scanned for prohibited words
+	ExcludedEntities map[string]bool
+}
+
+// a reset of scores that waits for the admin to confirm it
+type ScoresReset struct {
+	// 0 resets scores of everyone
+	UserId int64
+	// empty to reset scores for all the words
+	Word        string
+	RequestedBy int64
+	RequestedAt time.Time
+}
+
 type StaticProccessStructs struct {
 	Config     *StaticConfiguration
 	Chat       chat.Chat
 	Db         *database.Database
+	// translations to the default language
 	Trans      i18n.TranslateFunc
-	CachedWords map[int64][]string
+	CachedWords map[int64]*ChatWords
+	// resets waiting for confirmation by chats
+	PendingResets map[int64]*ScoresReset
 }
@@ -0,0 +1,305 @@
+package schedule
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minutesInDay = 24 * 60
+
+// days in the order they are written, the week starts on Monday
+var weekDays = []time.Weekday{
+	time.Monday,
+	time.Tuesday,
+	time.Wednesday,
+	time.Thursday,
+	time.Friday,
+	time.Saturday,
+	time.Sunday,
+}
+
+// names of weekDays, the first ones are used for formatting
+var dayNames = [][]string{
+	{"mon", "пн"},
+	{"tue", "вт"},
+	{"wed", "ср"},
+	{"thu", "чт"},
+	{"fri", "пт"},
+	{"sat", "сб"},
+	{"sun", "вс"},
+}
+
+// "+3", "UTC+03:00", "GMT-5:30"
+var offsetRegexp = regexp.MustCompile(`^(?i:utc|gmt)?([+-])(\d{1,2})(?::(\d{2}))?$`)
+
+// a range of minutes of the day on some days of the week,
+// the range can go past midnight, then its end belongs to the next day
+type window struct {
+	days [7]bool
+	from int
+	to   int
+}
+
+// Schedule is a set of weekly windows, an empty schedule is always active
+type Schedule struct {
+	windows []window
+}
+
+func getDayName(day time.Weekday) string {
+	for i, weekDay := range weekDays {
+		if weekDay == day {
+			return dayNames[i][0]
+		}
+	}
+	return ""
+}
+
+func parseDay(text string) (time.Weekday, error) {
+	text = strings.ToLower(text)
+	for i, names := range dayNames {
+		for _, name := range names {
+			if name == text {
+				return weekDays[i], nil
+			}
+		}
+	}
+	return time.Sunday, fmt.Errorf("unknown day '%s'", text)
+}
+
+// "mon" or "mon-fri", ranges can go through Sunday ("fri-mon")
+func parseDays(text string, days *[7]bool) error {
+	bounds := strings.SplitN(text, "-", 2)
+
+	first, err := parseDay(bounds[0])
+	if err != nil {
+		return err
+	}
+
+	last := first
+	if len(bounds) > 1 {
+		last, err = parseDay(bounds[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	for day := first; ; day = (day + 1) % 7 {
+		days[day] = true
+		if day == last {
+			break
+		}
+	}
+	return nil
+}
+
+// "9", "09:30" or "24:00"
+func parseTime(text string) (minutes int, err error) {
+	parts := strings.SplitN(text, ":", 2)
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 24 {
+		return 0, fmt.Errorf("wrong time '%s'", text)
+	}
+
+	if len(parts) > 1 {
+		minutes, err = strconv.Atoi(parts[1])
+		if err != nil || len(parts[1]) != 2 || minutes < 0 || minutes > 59 {
+			return 0, fmt.Errorf("wrong time '%s'", text)
+		}
+	}
+
+	minutes += hours * 60
+	if minutes > minutesInDay {
+		return 0, fmt.Errorf("wrong time '%s'", text)
+	}
+	return
+}
+
+// "9-18", "22:00-06:00"
+func parseHours(text string) (from int, to int, err error) {
+	bounds := strings.Split(text, "-")
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("wrong hours '%s'", text)
+	}
+
+	from, err = parseTime(bounds[0])
+	if err != nil {
+		return
+	}
+
+	to, err = parseTime(bounds[1])
+	if err != nil {
+		return
+	}
+
+	if from == minutesInDay || from == to {
+		err = fmt.Errorf("wrong hours '%s'", text)
+	}
+	return
+}
+
+// a window is days and hour ranges separated by spaces or commas: "mon-fri 9-13 14-18", "sat, sun"
+// no days means every day, no hours means the whole day
+func parseWindows(text string) (windows []window, err error) {
+	var days [7]bool
+	hasDays := false
+	hours := [][2]int{}
+
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' }) {
+		if strings.ContainsAny(part, "0123456789") {
+			from, to, err := parseHours(part)
+			if err != nil {
+				return nil, err
+			}
+			hours = append(hours, [2]int{from, to})
+		} else {
+			if err := parseDays(part, &days); err != nil {
+				return nil, err
+			}
+			hasDays = true
+		}
+	}
+
+	if !hasDays && len(hours) == 0 {
+		return nil, fmt.Errorf("empty window")
+	}
+
+	if !hasDays {
+		days = [7]bool{true, true, true, true, true, true, true}
+	}
+
+	if len(hours) == 0 {
+		hours = append(hours, [2]int{0, minutesInDay})
+	}
+
+	for _, hourRange := range hours {
+		windows = append(windows, window{days: days, from: hourRange[0], to: hourRange[1]})
+	}
+	return
+}
+
+// Parse reads windows separated by ";": "mon-fri 22:00-06:00; sat,sun"
+func Parse(text string) (schedule Schedule, err error) {
+	for _, windowText := range strings.Split(text, ";") {
+		if len(strings.TrimSpace(windowText)) == 0 {
+			continue
+		}
+
+		windows, err := parseWindows(windowText)
+		if err != nil {
+			return Schedule{}, err
+		}
+		schedule.windows = append(schedule.windows, windows...)
+	}
+
+	if len(schedule.windows) == 0 {
+		err = fmt.Errorf("empty schedule")
+	}
+	return
+}
+
+func (w window) isActive(day time.Weekday, minute int) bool {
+	if w.from < w.to {
+		return w.days[day] && minute >= w.from && minute < w.to
+	}
+	// the part after midnight belongs to the window of the previous day
+	return (w.days[day] && minute >= w.from) || (w.days[(day+6)%7] && minute < w.to)
+}
+
+// IsActive tells if the time is inside any of the windows, the time should be in the chat's time zone
+func (schedule Schedule) IsActive(t time.Time) bool {
+	if len(schedule.windows) == 0 {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range schedule.windows {
+		if w.isActive(t.Weekday(), minute) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatTime(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// consecutive days are joined into ranges: "mon-wed,sat"
+func formatDays(days [7]bool) string {
+	ranges := []string{}
+	for i := 0; i < len(weekDays); i++ {
+		if !days[weekDays[i]] {
+			continue
+		}
+
+		last := i
+		for last+1 < len(weekDays) && days[weekDays[last+1]] {
+			last++
+		}
+
+		if last == i {
+			ranges = append(ranges, getDayName(weekDays[i]))
+		} else {
+			ranges = append(ranges, getDayName(weekDays[i])+"-"+getDayName(weekDays[last]))
+		}
+		i = last
+	}
+	return strings.Join(ranges, ",")
+}
+
+func (w window) String() string {
+	parts := []string{}
+	if w.days != [7]bool{true, true, true, true, true, true, true} {
+		parts = append(parts, formatDays(w.days))
+	}
+	if w.from != 0 || w.to != minutesInDay {
+		parts = append(parts, formatTime(w.from)+"-"+formatTime(w.to))
+	}
+	if len(parts) == 0 {
+		return formatDays(w.days)
+	}
+	return strings.Join(parts, " ")
+}
+
+// String returns the schedule in the form that Parse reads
+func (schedule Schedule) String() string {
+	windows := []string{}
+	for _, w := range schedule.windows {
+		windows = append(windows, w.String())
+	}
+	return strings.Join(windows, "; ")
+}
+
+// LoadLocation reads a time zone name ("Europe/Moscow") or an offset from UTC ("+3", "UTC-05:30")
+func LoadLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+
+	if match := offsetRegexp.FindStringSubmatch(name); match != nil {
+		hours, _ := strconv.Atoi(match[2])
+		minutes := 0
+		if len(match[3]) > 0 {
+			minutes, _ = strconv.Atoi(match[3])
+		}
+
+		if hours > 14 || minutes > 59 {
+			return nil, fmt.Errorf("wrong offset '%s'", name)
+		}
+
+		offset := hours*60*60 + minutes*60
+		if match[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(fmt.Sprintf("UTC%s%02d:%02d", match[1], hours, minutes), offset), nil
+	}
+
+	// an empty name and "Local" are valid for time.LoadLocation but mean something else here
+	if len(name) == 0 || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone '%s'", name)
+	}
+
+	return time.LoadLocation(name)
+}
@@ -0,0 +1,84 @@
+package schedule
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// 2024-01-01 is Monday
+func makeTime(day int, hour int, minute int) time.Time {
+	return time.Date(2024, time.January, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseSchedule(t *testing.T) {
+	assert := require.New(t)
+
+	checkFormat := func(text string, expected string) {
+		schedule, err := Parse(text)
+		assert.Nil(err, text)
+		assert.Equal(expected, schedule.String())
+	}
+
+	checkFormat("mon-fri 9-18", "mon-fri 09:00-18:00")
+	checkFormat("Sat, Sun", "sat-sun")
+	checkFormat("22:00-06:00", "22:00-06:00")
+	checkFormat("пн,ср,чт 10:30-24:00", "mon,wed-thu 10:30-24:00")
+	checkFormat("fri-mon", "mon,fri-sun")
+	checkFormat("mon-fri 9-13 14-18; sat", "mon-fri 09:00-13:00; mon-fri 14:00-18:00; sat")
+	checkFormat("mon-sun 0-24", "mon-sun")
+
+	for _, text := range []string{"", " ; ", "someday", "mon 25-26", "mon 9", "mon 9:5-10", "10-10", "24-6", "mon 9-18-20"} {
+		_, err := Parse(text)
+		assert.NotNil(err, text)
+	}
+}
+
+func TestScheduleIsActive(t *testing.T) {
+	assert := require.New(t)
+
+	weekends, _ := Parse("sat,sun")
+	assert.False(weekends.IsActive(makeTime(5, 23, 59)))
+	assert.True(weekends.IsActive(makeTime(6, 0, 0)))
+	assert.True(weekends.IsActive(makeTime(7, 23, 59)))
+	assert.False(weekends.IsActive(makeTime(8, 0, 0)))
+
+	workHours, _ := Parse("mon-fri 9-18")
+	assert.True(workHours.IsActive(makeTime(1, 9, 0)))
+	assert.True(workHours.IsActive(makeTime(5, 17, 59)))
+	assert.False(workHours.IsActive(makeTime(5, 18, 0)))
+	assert.False(workHours.IsActive(makeTime(6, 12, 0)))
+
+	// the part after midnight belongs to the previous day
+	nights, _ := Parse("fri 22-6")
+	assert.False(nights.IsActive(makeTime(5, 5, 0)))
+	assert.True(nights.IsActive(makeTime(5, 22, 0)))
+	assert.True(nights.IsActive(makeTime(6, 5, 59)))
+	assert.False(nights.IsActive(makeTime(6, 6, 0)))
+	assert.False(nights.IsActive(makeTime(6, 22, 0)))
+
+	// an empty schedule is always active
+	assert.True(Schedule{}.IsActive(makeTime(1, 0, 0)))
+}
+
+func TestLoadLocation(t *testing.T) {
+	assert := require.New(t)
+
+	location, err := LoadLocation("+3")
+	assert.Nil(err)
+	assert.Equal("UTC+03:00", location.String())
+	assert.Equal(21, makeTime(1, 18, 0).In(location).Hour())
+
+	location, err = LoadLocation("UTC-05:30")
+	assert.Nil(err)
+	assert.Equal("UTC-05:30", location.String())
+
+	location, err = LoadLocation("Europe/Moscow")
+	assert.Nil(err)
+	assert.Equal("Europe/Moscow", location.String())
+
+	for _, name := range []string{"", "Local", "+15", "Mars/Olympus"} {
+		_, err = LoadLocation(name)
+		assert.NotNil(err, name)
+	}
+}
@@ -0,0 +1,364 @@
+package stemming
+
+import (
+	"strings"
+)
+
+// Snowball (Porter2) stemming algorithm for English
+// http://snowball.tartarus.org/algorithms/english/stemmer.html
+
+var englishExceptions = map[string]string{
+	"skis":   "ski",
+	"skies":  "sky",
+	"dying":  "die",
+	"lying":  "lie",
+	"tying":  "tie",
+	"idly":   "idl",
+	"gently": "gentl",
+	"ugly":   "ugli",
+	"early":  "earli",
+	"only":   "onli",
+	"singly": "singl",
+	"sky":    "sky",
+	"news":   "news",
+	"howe":   "howe",
+	"atlas":  "atlas",
+	"cosmos": "cosmos",
+	"bias":   "bias",
+	"andes":  "andes",
+}
+
+var englishStep1aExceptions = map[string]bool{
+	"inning":  true,
+	"outing":  true,
+	"canning": true,
+	"herring": true,
+	"earring": true,
+	"proceed": true,
+	"exceed":  true,
+	"succeed": true,
+}
+
+var englishStep2Endings = []struct {
+	ending      string
+	replacement string
+}{
+	{"ational", "ate"},
+	{"tional", "tion"},
+	{"enci", "ence"},
+	{"anci", "ance"},
+	{"abli", "able"},
+	{"entli", "ent"},
+	{"ization", "ize"},
+	{"izer", "ize"},
+	{"ation", "ate"},
+	{"ator", "ate"},
+	{"alism", "al"},
+	{"aliti", "al"},
+	{"alli", "al"},
+	{"fulness", "ful"},
+	{"ousli", "ous"},
+	{"ousness", "ous"},
+	{"iveness", "ive"},
+	{"iviti", "ive"},
+	{"biliti", "ble"},
+	{"bli", "ble"},
+	{"ogi", "og"},
+	{"fulli", "ful"},
+	{"lessli", "less"},
+	{"li", ""},
+}
+
+var englishStep3Endings = []struct {
+	ending      string
+	replacement string
+}{
+	{"ational", "ate"},
+	{"tional", "tion"},
+	{"alize", "al"},
+	{"icate", "ic"},
+	{"iciti", "ic"},
+	{"ical", "ic"},
+	{"ful", ""},
+	{"ness", ""},
+	{"ative", ""},
+}
+
+var englishStep4Endings = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent",
+	"ism", "ate", "iti", "ous", "ive", "ize", "ion",
+}
+
+func isEnglishVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	default:
+		return false
+	}
+}
+
+type englishWord struct {
+	runes []rune
+	r1    int
+	r2    int
+}
+
+func (word *englishWord) hasSuffix(suffix string) bool {
+	return hasRuneSuffix(word.runes, []rune(suffix))
+}
+
+// position where the suffix starts, the word should have the suffix
+func (word *englishWord) suffixStart(suffix string) int {
+	return len(word.runes) - len([]rune(suffix))
+}
+
+func (word *englishWord) replaceSuffix(suffix string, replacement string) {
+	word.runes = append(word.runes[:word.suffixStart(suffix)], []rune(replacement)...)
+}
+
+func (word *englishWord) containsVowel(end int) bool {
+	for _, r := range word.runes[:end] {
+		if isEnglishVowel(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// checks if the word part ending at the given position ends with a short syllable
+func (word *englishWord) endsWithShortSyllable(end int) bool {
+	runes := word.runes[:end]
+	if len(runes) == 2 {
+		return isEnglishVowel(runes[0]) && !isEnglishVowel(runes[1])
+	}
+
+	if len(runes) >= 3 {
+		last := runes[len(runes)-1]
+		return !isEnglishVowel(runes[len(runes)-3]) &&
+			isEnglishVowel(runes[len(runes)-2]) &&
+			!isEnglishVowel(last) && last != 'w' && last != 'x' && last != 'Y'
+	}
+
+	return false
+}
+
+func (word *englishWord) isShort() bool {
+	return word.r1 >= len(word.runes) && word.endsWithShortSyllable(len(word.runes))
+}
+
+func (word *englishWord) endsWithDouble() bool {
+	length := len(word.runes)
+	if length < 2 || word.runes[length-1] != word.runes[length-2] {
+		return false
+	}
+
+	switch word.runes[length-1] {
+	case 'b', 'd', 'f', 'g', 'm', 'n', 'p', 'r', 't':
+		return true
+	default:
+		return false
+	}
+}
+
+func isValidLiEnding(r rune) bool {
+	return strings.ContainsRune("cdeghkmnrt", r)
+}
+
+func makeEnglishWord(word string) *englishWord {
+	runes := []rune(strings.TrimPrefix(word, "'"))
+
+	for i, r := range runes {
+		if r == 'y' && (i == 0 || isEnglishVowel(runes[i-1])) {
+			runes[i] = 'Y'
+		}
+	}
+
+	result := &englishWord{runes: runes}
+
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(word, prefix) {
+			result.r1 = len([]rune(prefix))
+			break
+		}
+	}
+
+	if result.r1 == 0 {
+		result.r1 = regionAfterVowelConsonant(runes, 0, isEnglishVowel)
+	}
+	result.r2 = regionAfterVowelConsonant(runes, result.r1, isEnglishVowel)
+
+	return result
+}
+
+func (word *englishWord) step0() {
+	for _, suffix := range []string{"'s'", "'s", "'"} {
+		if word.hasSuffix(suffix) {
+			word.replaceSuffix(suffix, "")
+			return
+		}
+	}
+}
+
+func (word *englishWord) step1a() {
+	if word.hasSuffix("sses") {
+		word.replaceSuffix("sses", "ss")
+	} else if word.hasSuffix("ied") || word.hasSuffix("ies") {
+		suffix := string(word.runes[len(word.runes)-3:])
+		if len(word.runes) > 4 {
+			word.replaceSuffix(suffix, "i")
+		} else {
+			word.replaceSuffix(suffix, "ie")
+		}
+	} else if word.hasSuffix("us") || word.hasSuffix("ss") {
+		return
+	} else if word.hasSuffix("s") {
+		if word.containsVowel(len(word.runes) - 2) {
+			word.replaceSuffix("s", "")
+		}
+	}
+}
+
+func (word *englishWord) step1b() {
+	for _, suffix := range []string{"eedly", "eed"} {
+		if word.hasSuffix(suffix) {
+			if word.suffixStart(suffix) >= word.r1 {
+				word.replaceSuffix(suffix, "ee")
+			}
+			return
+		}
+	}
+
+	for _, suffix := range []string{"ingly", "edly", "ing", "ed"} {
+		if word.hasSuffix(suffix) {
+			if word.containsVowel(word.suffixStart(suffix)) {
+				word.replaceSuffix(suffix, "")
+
+				if word.hasSuffix("at") || word.hasSuffix("bl") || word.hasSuffix("iz") {
+					word.runes = append(word.runes, 'e')
+				} else if word.endsWithDouble() {
+					word.runes = word.runes[:len(word.runes)-1]
+				} else if word.isShort() {
+					word.runes = append(word.runes, 'e')
+				}
+			}
+			return
+		}
+	}
+}
+
+func (word *englishWord) step1c() {
+	length := len(word.runes)
+	if length > 2 && (word.runes[length-1] == 'y' || word.runes[length-1] == 'Y') && !isEnglishVowel(word.runes[length-2]) {
+		word.runes[length-1] = 'i'
+	}
+}
+
+func (word *englishWord) step2() {
+	for _, rule := range englishStep2Endings {
+		if word.hasSuffix(rule.ending) {
+			start := word.suffixStart(rule.ending)
+			if start < word.r1 {
+				return
+			}
+
+			if rule.ending == "ogi" {
+				if start > 0 && word.runes[start-1] == 'l' {
+					word.replaceSuffix(rule.ending, rule.replacement)
+				}
+			} else if rule.ending == "li" {
+				if start > 0 && isValidLiEnding(word.runes[start-1]) {
+					word.replaceSuffix(rule.ending, rule.replacement)
+				}
+			} else {
+				word.replaceSuffix(rule.ending, rule.replacement)
+			}
+			return
+		}
+	}
+}
+
+func (word *englishWord) step3() {
+	for _, rule := range englishStep3Endings {
+		if word.hasSuffix(rule.ending) {
+			start := word.suffixStart(rule.ending)
+			if start < word.r1 {
+				return
+			}
+
+			if rule.ending == "ative" {
+				if start >= word.r2 {
+					word.replaceSuffix(rule.ending, rule.replacement)
+				}
+			} else {
+				word.replaceSuffix(rule.ending, rule.replacement)
+			}
+			return
+		}
+	}
+}
+
+func (word *englishWord) step4() {
+	longestEnding := ""
+	for _, ending := range englishStep4Endings {
+		if len(ending) > len(longestEnding) && word.hasSuffix(ending) {
+			longestEnding = ending
+		}
+	}
+
+	if longestEnding == "" {
+		return
+	}
+
+	start := word.suffixStart(longestEnding)
+	if start < word.r2 {
+		return
+	}
+
+	if longestEnding == "ion" {
+		if start > 0 && (word.runes[start-1] == 's' || word.runes[start-1] == 't') {
+			word.replaceSuffix(longestEnding, "")
+		}
+	} else {
+		word.replaceSuffix(longestEnding, "")
+	}
+}
+
+func (word *englishWord) step5() {
+	length := len(word.runes)
+	if word.hasSuffix("e") {
+		if length-1 >= word.r2 || (length-1 >= word.r1 && !word.endsWithShortSyllable(length-1)) {
+			word.runes = word.runes[:length-1]
+		}
+	} else if word.hasSuffix("ll") && length-1 >= word.r2 {
+		word.runes = word.runes[:length-1]
+	}
+}
+
+// EnglishStem expects a lowercase word
+func EnglishStem(word string) string {
+	if len([]rune(word)) <= 2 {
+		return word
+	}
+
+	if exception, ok := englishExceptions[word]; ok {
+		return exception
+	}
+
+	english := makeEnglishWord(word)
+	english.step0()
+	english.step1a()
+
+	if englishStep1aExceptions[string(english.runes)] {
+		return string(english.runes)
+	}
+
+	english.step1b()
+	english.step1c()
+	english.step2()
+	english.step3()
+	english.step4()
+	english.step5()
+
+	return strings.Replace(string(english.runes), "Y", "y", -1)
+}
@@ -0,0 +1,170 @@
+package stemming
+
+// Snowball stemming algorithm for Russian
+// http://snowball.tartarus.org/algorithms/russian/stemmer.html
+
+var russianPerfectiveGerund1 = []string{"в", "вши", "вшись"}
+var russianPerfectiveGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+
+var russianAdjective = []string{
+	"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+	"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+}
+
+var russianParticiple1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+var russianParticiple2 = []string{"ивш", "ывш", "ующ"}
+
+var russianReflexive = []string{"ся", "сь"}
+
+var russianVerb1 = []string{
+	"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно",
+}
+var russianVerb2 = []string{
+	"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+	"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю",
+}
+
+var russianNoun = []string{
+	"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+	"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я",
+}
+
+var russianSuperlative = []string{"ейш", "ейше"}
+var russianDerivational = []string{"ост", "ость"}
+
+func isRussianVowel(r rune) bool {
+	switch r {
+	case 'а', 'е', 'и', 'о', 'у', 'ы', 'э', 'ю', 'я':
+		return true
+	default:
+		return false
+	}
+}
+
+type russianWord struct {
+	runes []rune
+	// start of the RV and R2 regions
+	rv int
+	r2 int
+}
+
+func makeRussianWord(word string) *russianWord {
+	runes := []rune(word)
+	for i, r := range runes {
+		if r == 'ё' {
+			runes[i] = 'е'
+		}
+	}
+
+	result := &russianWord{
+		runes: runes,
+		rv:    len(runes),
+		r2:    len(runes),
+	}
+
+	for i, r := range runes {
+		if isRussianVowel(r) {
+			result.rv = i + 1
+			break
+		}
+	}
+
+	r1 := regionAfterVowelConsonant(runes, 0, isRussianVowel)
+	result.r2 = regionAfterVowelConsonant(runes, r1, isRussianVowel)
+
+	return result
+}
+
+// finds the longest suffix from the list that lays in the RV region
+func (word *russianWord) findEnding(endings []string) (length int) {
+	for _, ending := range endings {
+		endingRunes := []rune(ending)
+		if len(endingRunes) > length && len(word.runes)-len(endingRunes) >= word.rv && hasRuneSuffix(word.runes, endingRunes) {
+			length = len(endingRunes)
+		}
+	}
+	return
+}
+
+// same as findEnding but the first group requires "а" or "я" before the ending
+func (word *russianWord) findGroupedEnding(group1 []string, group2 []string) (length int) {
+	length1 := word.findEnding(group1)
+	length2 := word.findEnding(group2)
+
+	if length2 >= length1 {
+		return length2
+	}
+
+	precedingIdx := len(word.runes) - length1 - 1
+	if precedingIdx >= word.rv && (word.runes[precedingIdx] == 'а' || word.runes[precedingIdx] == 'я') {
+		return length1
+	}
+	return 0
+}
+
+func (word *russianWord) removeEnding(length int) bool {
+	if length > 0 {
+		word.runes = word.runes[:len(word.runes)-length]
+		return true
+	}
+	return false
+}
+
+func (word *russianWord) removeAdjectival() bool {
+	if word.removeEnding(word.findEnding(russianAdjective)) {
+		word.removeEnding(word.findGroupedEnding(russianParticiple1, russianParticiple2))
+		return true
+	}
+	return false
+}
+
+func (word *russianWord) step1() {
+	if word.removeEnding(word.findGroupedEnding(russianPerfectiveGerund1, russianPerfectiveGerund2)) {
+		return
+	}
+
+	word.removeEnding(word.findEnding(russianReflexive))
+
+	if word.removeAdjectival() {
+		return
+	}
+
+	if word.removeEnding(word.findGroupedEnding(russianVerb1, russianVerb2)) {
+		return
+	}
+
+	word.removeEnding(word.findEnding(russianNoun))
+}
+
+func (word *russianWord) step2() {
+	word.removeEnding(word.findEnding([]string{"и"}))
+}
+
+func (word *russianWord) step3() {
+	length := word.findEnding(russianDerivational)
+	if length > 0 && len(word.runes)-length >= word.r2 {
+		word.removeEnding(length)
+	}
+}
+
+func (word *russianWord) step4() {
+	if word.removeEnding(word.findEnding(russianSuperlative)) {
+		if word.findEnding([]string{"нн"}) > 0 {
+			word.removeEnding(1)
+		}
+	} else if word.findEnding([]string{"нн"}) > 0 {
+		word.removeEnding(1)
+	} else {
+		word.removeEnding(word.findEnding([]string{"ь"}))
+	}
+}
+
+// RussianStem expects a lowercase word
+func RussianStem(word string) string {
+	russian := makeRussianWord(word)
+	russian.step1()
+	russian.step2()
+	russian.step3()
+	russian.step4()
+	return string(russian.runes)
+}
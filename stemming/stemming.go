@@ -0,0 +1,56 @@
+package stemming
+
+import (
+	"strings"
+	"unicode"
+)
+
+func hasRuneSuffix(runes []rune, suffix []rune) bool {
+	if len(suffix) > len(runes) {
+		return false
+	}
+
+	offset := len(runes) - len(suffix)
+	for i, r := range suffix {
+		if runes[offset+i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// returns the index of the first non-vowel that follows a vowel starting from the given position
+func regionAfterVowelConsonant(runes []rune, start int, isVowel func(rune) bool) int {
+	for i := start + 1; i < len(runes); i++ {
+		if !isVowel(runes[i]) && isVowel(runes[i-1]) {
+			return i + 1
+		}
+	}
+	return len(runes)
+}
+
+// Stem returns the stem of a word choosing the language by the script of the word.
+// Words in other scripts or with mixed scripts are returned lowercased but not stemmed
+func Stem(word string) string {
+	lowerWord := strings.ToLower(word)
+
+	hasCyrillic := false
+	hasLatin := false
+	for _, r := range lowerWord {
+		if unicode.Is(unicode.Cyrillic, r) {
+			hasCyrillic = true
+		} else if unicode.Is(unicode.Latin, r) || r == '\'' {
+			hasLatin = true
+		} else {
+			return lowerWord
+		}
+	}
+
+	if hasCyrillic && !hasLatin {
+		return RussianStem(lowerWord)
+	} else if hasLatin && !hasCyrillic {
+		return EnglishStem(lowerWord)
+	} else {
+		return lowerWord
+	}
+}
@@ -0,0 +1,54 @@
+package stemming
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRussianStem(t *testing.T) {
+	assert := require.New(t)
+
+	for _, word := range []string{"работа", "работу", "работой", "работе", "работы", "работами"} {
+		assert.Equal("работ", RussianStem(word), word)
+	}
+
+	assert.Equal("важн", RussianStem("важнейшие"))
+	assert.Equal("валя", RussianStem("валяется"))
+	assert.Equal("вагон", RussianStem("вагона"))
+	assert.Equal("елк", RussianStem("ёлки"))
+}
+
+func TestEnglishStem(t *testing.T) {
+	assert := require.New(t)
+
+	testCases := map[string]string{
+		"consign":     "consign",
+		"consigned":   "consign",
+		"consignment": "consign",
+		"running":     "run",
+		"hopping":     "hop",
+		"hoping":      "hope",
+		"caresses":    "caress",
+		"ponies":      "poni",
+		"ties":        "tie",
+		"generously":  "generous",
+		"conditional": "condit",
+		"skies":       "sky",
+		"succeeding":  "succeed",
+		"is":          "is",
+	}
+
+	for word, stem := range testCases {
+		assert.Equal(stem, EnglishStem(word), word)
+	}
+}
+
+func TestStem(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal("работ", Stem("Работой"))
+	assert.Equal("run", Stem("Running"))
+	// mixed scripts and digits are not stemmed
+	assert.Equal("рaботой", Stem("рaботой"))
+	assert.Equal("test123", Stem("test123"))
+}
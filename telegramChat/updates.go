@@ -0,0 +1,175 @@
+package telegramChat
+
+import (
+	"encoding/json"
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type PollOption struct {
+	Text string `json:"text"`
+}
+
+type Poll struct {
+	Question string       `json:"question"`
+	Options  []PollOption `json:"options"`
+}
+
+type MessageOrigin struct {
+	// "user", "hidden_user", "chat" or "channel"
+	Type string `json:"type"`
+}
+
+type StickerExtras struct {
+	// unlike file_id it's the same for all bots
+	FileUniqueId string `json:"file_unique_id"`
+}
+
+// message fields that tgbotapi.Message doesn't have
+type MessageExtras struct {
+	Poll *Poll `json:"poll"`
+	// the same as Entities but for Caption
+	CaptionEntities []tgbotapi.MessageEntity `json:"caption_entities"`
+	// set for forwards from users who hide their accounts
+	ForwardSenderName string `json:"forward_sender_name"`
+	// newer replacement of all forward_* fields
+	ForwardOrigin *MessageOrigin `json:"forward_origin"`
+	Sticker       *StickerExtras `json:"sticker"`
+	// extras of the message this one replies to
+	ReplyToMessage *MessageExtras `json:"reply_to_message"`
+}
+
+type ChatMember struct {
+	User tgbotapi.User `json:"user"`
+	// "creator", "administrator", "member", "restricted", "left" or "kicked"
+	Status string `json:"status"`
+	// only for "restricted"
+	IsMember bool `json:"is_member"`
+}
+
+// a change of membership of a user in a chat
+type ChatMemberUpdated struct {
+	Chat          tgbotapi.Chat `json:"chat"`
+	OldChatMember ChatMember    `json:"old_chat_member"`
+	NewChatMember ChatMember    `json:"new_chat_member"`
+}
+
+type updateExtras struct {
+	Message       *MessageExtras     `json:"message"`
+	EditedMessage *MessageExtras     `json:"edited_message"`
+	ChatMember    *ChatMemberUpdated `json:"chat_member"`
+}
+
+type Update struct {
+	tgbotapi.Update
+	// extras of Message or EditedMessage, never nil
+	MessageExtras *MessageExtras
+	// nil if the update is not about chat members
+	ChatMember *ChatMemberUpdated
+}
+
+// kinds of updates that the bot asks for, chat members are not sent without asking
+var allowedUpdates = []string{"message", "edited_message", "chat_member"}
+
+// IsPresent tells if the user is in the chat
+func (member ChatMember) IsPresent() bool {
+	switch member.Status {
+	case "creator", "administrator", "member":
+		return true
+	case "restricted":
+		return member.IsMember
+	default:
+		return false
+	}
+}
+
+func parseUpdate(rawUpdate json.RawMessage) (update Update, err error) {
+	update.MessageExtras = &MessageExtras{}
+
+	err = json.Unmarshal(rawUpdate, &update.Update)
+	if err != nil {
+		return
+	}
+
+	var extras updateExtras
+	err = json.Unmarshal(rawUpdate, &extras)
+	if err != nil {
+		return
+	}
+
+	update.ChatMember = extras.ChatMember
+
+	if extras.Message != nil {
+		update.MessageExtras = extras.Message
+	} else if extras.EditedMessage != nil {
+		update.MessageExtras = extras.EditedMessage
+	}
+
+	return
+}
+
+func (telegramChat *TelegramChat) getUpdates(offset int, timeout int) (updates []Update, err error) {
+	values := url.Values{}
+	if offset != 0 {
+		values.Add("offset", strconv.Itoa(offset))
+	}
+	values.Add("timeout", strconv.Itoa(timeout))
+
+	allowedUpdatesJson, err := json.Marshal(allowedUpdates)
+	if err != nil {
+		return
+	}
+	values.Add("allowed_updates", string(allowedUpdatesJson))
+
+	response, err := telegramChat.bot.MakeRequest("getUpdates", values)
+	if err != nil {
+		return
+	}
+
+	var rawUpdates []json.RawMessage
+	err = json.Unmarshal(response.Result, &rawUpdates)
+	if err != nil {
+		return
+	}
+
+	for _, rawUpdate := range rawUpdates {
+		update, err := parseUpdate(rawUpdate)
+		if err != nil {
+			// keep the update anyway so its id is not requested again
+			log.Printf("Can't parse update: %s", err.Error())
+		}
+		updates = append(updates, update)
+	}
+
+	return
+}
+
+// same as tgbotapi.BotAPI.GetUpdatesChan but keeps the fields that tgbotapi doesn't know about
+func (telegramChat *TelegramChat) GetUpdatesChan(timeout int) <-chan Update {
+	updatesChan := make(chan Update, telegramChat.bot.Buffer)
+
+	go func() {
+		offset := 0
+		for {
+			updates, err := telegramChat.getUpdates(offset, timeout)
+			if err != nil {
+				log.Println(err)
+				log.Println("Failed to get updates, retrying in 3 seconds...")
+				time.Sleep(time.Second * 3)
+				continue
+			}
+
+			for _, update := range updates {
+				if update.UpdateID >= offset {
+					offset = update.UpdateID + 1
+					updatesChan <- update
+				}
+			}
+		}
+	}()
+
+	return updatesChan
+}
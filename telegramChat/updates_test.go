@@ -0,0 +1,76 @@
+package telegramChat
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseUpdate(t *testing.T) {
+	assert := require.New(t)
+
+	{
+		update, err := parseUpdate([]byte(`{"update_id":1,"message":{"message_id":2,"text":"text","poll":{"id":"3","question":"question","options":[{"text":"option1","voter_count":0},{"text":"option2","voter_count":0}]}}}`))
+		assert.NoError(err)
+		assert.Equal(1, update.UpdateID)
+		assert.Equal(2, update.Message.MessageID)
+		assert.Equal("text", update.Message.Text)
+		assert.NotNil(update.MessageExtras.Poll)
+		assert.Equal("question", update.MessageExtras.Poll.Question)
+		assert.Equal([]PollOption{{Text: "option1"}, {Text: "option2"}}, update.MessageExtras.Poll.Options)
+	}
+
+	{
+		update, err := parseUpdate([]byte(`{"update_id":4,"edited_message":{"message_id":5,"caption":"caption"}}`))
+		assert.NoError(err)
+		assert.Nil(update.Message)
+		assert.Equal("caption", update.EditedMessage.Caption)
+		assert.NotNil(update.MessageExtras)
+		assert.Nil(update.MessageExtras.Poll)
+	}
+
+	{
+		update, err := parseUpdate([]byte(`{"update_id":6,"callback_query":{"id":"7"}}`))
+		assert.NoError(err)
+		assert.Equal(6, update.UpdateID)
+		assert.NotNil(update.MessageExtras)
+	}
+
+	{
+		update, err := parseUpdate([]byte(`{"update_id":8,"message":{"message_id":9,"text":"text","forward_date":10,"forward_sender_name":"name","forward_origin":{"type":"hidden_user","date":10,"sender_user_name":"name"}}}`))
+		assert.NoError(err)
+		assert.Equal(10, update.Message.ForwardDate)
+		assert.Equal("name", update.MessageExtras.ForwardSenderName)
+		assert.Equal("hidden_user", update.MessageExtras.ForwardOrigin.Type)
+	}
+
+	{
+		update, err := parseUpdate([]byte(`{"update_id":11,"message":{"message_id":12,"text":"/ban_sticker","reply_to_message":{"message_id":13,"sticker":{"file_id":"id","file_unique_id":"unique","set_name":"set"}}}}`))
+		assert.NoError(err)
+		assert.Nil(update.MessageExtras.Sticker)
+		assert.Equal("set", update.Message.ReplyToMessage.Sticker.SetName)
+		assert.Equal("unique", update.MessageExtras.ReplyToMessage.Sticker.FileUniqueId)
+	}
+
+	{
+		update, err := parseUpdate([]byte(`{"update_id":14,"chat_member":{"chat":{"id":-15,"type":"supergroup"},"from":{"id":16,"first_name":"admin"},"date":17,"old_chat_member":{"user":{"id":18,"first_name":"user"},"status":"member"},"new_chat_member":{"user":{"id":18,"first_name":"user"},"status":"kicked","until_date":0}}}`))
+		assert.NoError(err)
+		assert.Nil(update.Message)
+		assert.NotNil(update.ChatMember)
+		assert.Equal(int64(-15), update.ChatMember.Chat.ID)
+		assert.Equal(18, update.ChatMember.NewChatMember.User.ID)
+		assert.True(update.ChatMember.OldChatMember.IsPresent())
+		assert.False(update.ChatMember.NewChatMember.IsPresent())
+	}
+}
+
+func TestChatMemberPresence(t *testing.T) {
+	assert := require.New(t)
+
+	assert.True(ChatMember{Status: "creator"}.IsPresent())
+	assert.True(ChatMember{Status: "administrator"}.IsPresent())
+	assert.True(ChatMember{Status: "member"}.IsPresent())
+	assert.True(ChatMember{Status: "restricted", IsMember: true}.IsPresent())
+	assert.False(ChatMember{Status: "restricted", IsMember: false}.IsPresent())
+	assert.False(ChatMember{Status: "left"}.IsPresent())
+	assert.False(ChatMember{Status: "kicked"}.IsPresent())
+}